@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+// deleteAccount schedules the authenticated user's account for deletion.
+// The account can be restored until the grace period elapses, after which
+// purgeDeletedAccounts removes it for good.
+func (cfg *apiConfig) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	var p struct {
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	if err := auth.CheckPasswordHash(user.HashedPassword, p.Password); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "incorrect password")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not delete account at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete account at this time")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	deleted, err := qtx.ScheduleUserDeletion(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "account is already scheduled for deletion")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not delete account at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete account at this time")
+		return
+	}
+	if err := qtx.RevokeAllRefreshTokensForUser(r.Context(), userID); err != nil {
+		loggerFrom(r.Context()).Error("could not delete account at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete account at this time")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		loggerFrom(r.Context()).Error("could not delete account at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete account at this time")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"id":          deleted.ID.String(),
+		"deleted_at":  deleted.DeletedAt.Time.String(),
+		"purge_after": deleted.DeletedAt.Time.Add(cfg.deletionGracePeriod).String(),
+	})
+}
+
+// restoreAccount cancels a pending deletion as long as the grace period has
+// not elapsed yet. It authenticates with email and password because every
+// session was revoked when the deletion was requested.
+func (cfg *apiConfig) restoreAccount(w http.ResponseWriter, r *http.Request) {
+	var p struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), p.Email)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+		return
+	}
+	if err := auth.CheckPasswordHash(user.HashedPassword, p.Password); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+		return
+	}
+	if !user.DeletedAt.Valid {
+		respondWithError(w, http.StatusConflict, "account is not scheduled for deletion")
+		return
+	}
+
+	restored, err := cfg.dbQueries.RestoreUser(r.Context(), database.RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: time.Now().Add(-cfg.deletionGracePeriod),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusGone, "grace period for restoring this account has expired")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not restore account at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not restore account at this time")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"id":            restored.ID.String(),
+		"created_at":    restored.CreatedAt.String(),
+		"updated_at":    restored.UpdatedAt.String(),
+		"email":         restored.Email,
+		"is_chirpy_red": isChirpyRed(restored.ChirpyRedExpiresAt),
+	})
+}
+
+// purgeDeletedAccounts removes accounts whose deletion grace period has
+// elapsed. Their chirps and refresh tokens are removed along with them by
+// the ON DELETE CASCADE foreign keys.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context, _ struct{}) error {
+	purged, err := cfg.dbQueries.PurgeDeletedUsers(ctx, time.Now().Add(-cfg.deletionGracePeriod))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		loggerFrom(ctx).Info("purged deleted accounts", "count", purged)
+	}
+	return nil
+}
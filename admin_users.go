@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := cfg.dbQueries.ListUsers(r.Context())
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve users", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve users")
+		return
+	}
+	res := make([]map[string]any, 0, len(users))
+	for _, user := range users {
+		res = append(res, map[string]any{
+			"id":               user.ID.String(),
+			"created_at":       user.CreatedAt.String(),
+			"updated_at":       user.UpdatedAt.String(),
+			"email":            user.Email,
+			"role":             user.Role,
+			"is_chirpy_red":    isChirpyRed(user.ChirpyRedExpiresAt),
+			"suspended_until":  nullableTime(user.SuspendedUntil),
+			"banned_at":        nullableTime(user.BannedAt),
+			"shadow_banned_at": nullableTime(user.ShadowBannedAt),
+			"deleted_at":       nullableTime(user.DeletedAt),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) updateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	var p struct {
+		Role auth.Role `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	if !p.Role.Valid() {
+		respondWithError(w, http.StatusBadRequest, "role must be one of user, moderator or admin")
+		return
+	}
+	if caller, ok := authenticatedUserFromContext(r.Context()); ok && caller.ID == userID {
+		respondWithError(w, http.StatusForbidden, "you cannot change your own role")
+		return
+	}
+	// The user's sessions are revoked along with the change, so that they
+	// sign in again with a token carrying the new role.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not update role", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not update role")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	user, err := qtx.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{ID: userID, Role: string(p.Role)})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	if err == nil {
+		err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not update role", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not update role")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"id":         user.ID.String(),
+		"created_at": user.CreatedAt.String(),
+		"updated_at": user.UpdatedAt.String(),
+		"email":      user.Email,
+		"role":       user.Role,
+	})
+}
+
+// checkModerationTarget refuses moderation actions against the caller
+// themselves or against anyone whose role isn't below theirs, so that a
+// moderator can't lock an admin out.
+func checkModerationTarget(w http.ResponseWriter, caller authenticatedUser, target database.User) bool {
+	if caller.ID == target.ID {
+		respondWithError(w, http.StatusForbidden, "you cannot moderate yourself")
+		return false
+	}
+	if !caller.Role.Outranks(auth.Role(target.Role)) {
+		respondWithError(w, http.StatusForbidden, "you cannot moderate a user with the same or a higher role")
+		return false
+	}
+	return true
+}
+
+// suspendAccount suspends a user until the given time and revokes their
+// sessions, so that the suspension takes effect within a token lifetime.
+func suspendAccount(ctx context.Context, q *database.Queries, userID uuid.UUID, until time.Time) error {
+	err := q.SuspendUser(ctx, database.SuspendUserParams{
+		ID:             userID,
+		SuspendedUntil: sql.NullTime{Time: until, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return q.RevokeAllRefreshTokensForUser(ctx, userID)
+}
+
+// suspendUser suspends an account outside of the report queue. Like the
+// report actions it is recorded in moderation_actions, and the user's
+// sessions are revoked so the suspension takes effect within a token
+// lifetime.
+func (cfg *apiConfig) suspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	var p struct {
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	if p.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "a reason is required")
+		return
+	}
+	if p.SuspendedUntil != nil && !p.SuspendedUntil.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future")
+		return
+	}
+	target, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	caller, _ := authenticatedUserFromContext(r.Context())
+	if !checkModerationTarget(w, caller, target) {
+		return
+	}
+	until := time.Now().Add(defaultSuspension)
+	if p.SuspendedUntil != nil {
+		until = *p.SuspendedUntil
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not suspend user", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not suspend user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	err = suspendAccount(r.Context(), qtx, userID, until)
+	if err == nil {
+		_, err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ActorID:      uuid.NullUUID{UUID: caller.ID, Valid: true},
+			Action:       "suspend_user",
+			TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+			Reason:       p.Reason,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not suspend user", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not suspend user")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"id":              userID.String(),
+		"suspended_until": until.String(),
+	})
+}
+
+func (cfg *apiConfig) revokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	if err := cfg.dbQueries.RevokeAllRefreshTokensForUser(r.Context(), userID); err != nil {
+		loggerFrom(r.Context()).Error("could not revoke sessions", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not revoke sessions")
+		return
+	}
+	RespondNoContent(w, r)
+}
+
+// restrictUser returns a handler that applies or lifts a ban, shadow ban or
+// suspension on the user in the path. action is the moderation_actions
+// action it is recorded as.
+func (cfg *apiConfig) restrictUser(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "could not find user")
+			return
+		}
+		var p struct {
+			Reason string `json:"reason"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		defer r.Body.Close()
+		if err := decoder.Decode(&p); err != nil {
+			if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+				loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+			}
+			return
+		}
+		if p.Reason == "" {
+			respondWithError(w, http.StatusBadRequest, "a reason is required")
+			return
+		}
+		target, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "could not find user")
+			return
+		}
+		caller, _ := authenticatedUserFromContext(r.Context())
+		if !checkModerationTarget(w, caller, target) {
+			return
+		}
+
+		tx, err := cfg.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			loggerFrom(r.Context()).Error("could not update user", "error", err)
+			respondWithError(w, http.StatusInternalServerError, "could not update user")
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.withTx(tx)
+
+		now := sql.NullTime{Time: time.Now(), Valid: true}
+		switch action {
+		case "ban_user":
+			err = qtx.SetUserBan(r.Context(), database.SetUserBanParams{ID: userID, BannedAt: now})
+			if err == nil {
+				err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+			}
+		case "unban_user":
+			err = qtx.SetUserBan(r.Context(), database.SetUserBanParams{ID: userID})
+		case "shadow_ban_user":
+			err = qtx.SetUserShadowBan(r.Context(), database.SetUserShadowBanParams{ID: userID, ShadowBannedAt: now})
+		case "unshadow_ban_user":
+			err = qtx.SetUserShadowBan(r.Context(), database.SetUserShadowBanParams{ID: userID})
+		case "unsuspend_user":
+			err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{ID: userID})
+		default:
+			err = fmt.Errorf("unknown restriction %q", action)
+		}
+		if err == nil {
+			_, err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+				ActorID:      uuid.NullUUID{UUID: caller.ID, Valid: true},
+				Action:       action,
+				TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+				Reason:       p.Reason,
+			})
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			loggerFrom(r.Context()).Error("could not update user", "error", err)
+			respondWithError(w, http.StatusInternalServerError, "could not update user")
+			return
+		}
+		RespondNoContent(w, r)
+	}
+}
@@ -1,53 +1,135 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"sync/atomic"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/config"
 	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/entitlements"
+	"github.com/wilgnert/chirpy/internal/jobs"
+	"github.com/wilgnert/chirpy/internal/metrics"
+	"github.com/wilgnert/chirpy/internal/moderation"
+	"github.com/wilgnert/chirpy/internal/ratelimit"
+	"github.com/wilgnert/chirpy/internal/spam"
+	"github.com/wilgnert/chirpy/internal/tracing"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
+	metrics *metrics.Metrics
+	metricsToken string
+	db *sql.DB
 	dbQueries *database.Queries
 	plataform string
 	secret string
 	polka_key string
+	polkaWebhookSecrets []string
+	polkaWebhookTolerance time.Duration
+	polkaAllowAPIKey bool
+	billing billing.Policy
+	deletionGracePeriod time.Duration
+	moderation *moderation.Engine
+	moderationWordsFile string
+	rateLimiter ratelimit.Limiter
+	trustProxyHeaders bool
+	spam *spam.Detector
+	entitlements entitlements.Config
+	jobs *jobs.Queue
+	shutdownTracing func(context.Context) error
+	shuttingDown atomic.Bool
+	tokens config.Tokens
+	server config.Server
+	stopWorkers context.CancelFunc
+	dispatcherDone <-chan struct{}
 }
 
-func (cfg *apiConfig) init() error {
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	db, err := sql.Open("postgres", dbURL)
+// init connects to the database and sets up everything the handlers need
+// from the validated configuration c.
+func (cfg *apiConfig) init(c config.Config) error {
+	db, err := sql.Open("postgres", c.DatabaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to connect to db: %w", err)	
 	}
-	cfg.dbQueries = database.New(db)
-	cfg.plataform = os.Getenv("PLATAFORM")
-	cfg.secret = os.Getenv("SECRET")
-	cfg.polka_key = os.Getenv("POLKA_KEY")
+	cfg.db = db
+	cfg.shutdownTracing, err = tracing.Setup(context.Background(), c.TraceExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	cfg.metrics = metrics.New()
+	cfg.dbQueries = database.New(cfg.instrumentDB(db))
+	cfg.jobs = jobs.NewQueue(cfg.instrumentDB(db))
+	cfg.registerMetrics()
+	cfg.plataform = c.Platform
+	cfg.metricsToken = c.MetricsToken
+	cfg.secret = c.Secret
+	cfg.tokens = c.Tokens
+	cfg.polka_key = c.Polka.APIKey
+	cfg.polkaWebhookSecrets = c.Polka.WebhookSecrets
+	cfg.polkaWebhookTolerance = c.Polka.WebhookTolerance
+	cfg.polkaAllowAPIKey = c.Polka.AllowAPIKey
+	cfg.billing = billing.DefaultPolicy()
+	cfg.billing.GracePeriod = c.ChirpyRedGracePeriod
+	cfg.deletionGracePeriod = c.AccountDeletionGracePeriod
+	switch c.RateLimitStore {
+	case "memory":
+		cfg.rateLimiter = ratelimit.NewMemoryLimiter()
+	case "postgres":
+		cfg.rateLimiter = ratelimit.NewPostgresLimiter(db)
+	}
+	cfg.registerJobs()
+	cfg.trustProxyHeaders = c.TrustProxyHeaders
+	cfg.moderation, _ = moderation.NewEngine(nil)
+	cfg.moderationWordsFile = c.ModerationWordsFile
+	if err := cfg.reloadModeration(context.Background()); err != nil {
+		return fmt.Errorf("failed to load moderation rules: %w", err)
+	}
+	cfg.entitlements = entitlements.DefaultConfig()
+	if c.EntitlementsFile != "" {
+		cfg.entitlements, err = entitlements.LoadFile(c.EntitlementsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load entitlements: %w", err)
+		}
+	}
+	cfg.server = c.Server
+	cfg.spam, err = loadSpamDetector(c.Spam)
+	if err != nil {
+		return fmt.Errorf("failed to configure spam detection: %w", err)
+	}
 	return nil
 }
 
 
-func HandleHealth(w http.ResponseWriter, r *http.Request) {
-	RespondOK(w, r)
+// instrumentDB times and traces every query made through db.
+func (cfg *apiConfig) instrumentDB(db database.DBTX) database.DBTX {
+	return tracing.TraceDB(cfg.metrics.InstrumentDB(db))
 }
 
-func (cfg *apiConfig) showMetrics(w http.ResponseWriter, r *http.Request) {
-	io.WriteString(w, fmt.Sprintf(`<html>
-  <body>
-    <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p>
-  </body>
-</html>`, cfg.fileserverHits.Load()))
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+// withTx returns queries bound to tx that are timed and traced like
+// cfg.dbQueries.
+func (cfg *apiConfig) withTx(tx *sql.Tx) *database.Queries {
+	return database.New(cfg.instrumentDB(tx))
+}
+
+// registerMetrics adds the gauges that are read from the database at
+// scrape time.
+func (cfg *apiConfig) registerMetrics() {
+	cfg.metrics.GaugeFunc("chirpy_active_sessions", "Refresh tokens that are neither expired nor revoked.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		count, err := cfg.dbQueries.CountActiveRefreshTokens(ctx)
+		if err != nil {
+			loggerFrom(ctx).Error("counting active sessions failed", "error", err)
+			return 0
+		}
+		return float64(count)
+	})
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) error {
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
-	"sort"
+	"net/url"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/wilgnert/chirpy/internal/auth"
 	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/spam"
+	"github.com/wilgnert/chirpy/internal/webhooks"
 )
 
 func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
@@ -19,9 +23,9 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	defer r.Body.Close()
 	if err := decoder.Decode(&p); err != nil {
-		fmt.Println(err.Error())
+		loggerFrom(r.Context()).Error("could not decode chirp", "error", err)
 		if err := respondWithError(w, http.StatusInternalServerError, "Something went wrong"); err != nil {
-			fmt.Println("Could not respond to request")
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 		}
 		return
 	}
@@ -30,18 +34,40 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "invalid token")
 		return
 	}
-	parsedID, err := auth.ValidateJWT(bearerToken, cfg.secret)
+	parsedID, err := cfg.validateAccessToken(r.Context(), bearerToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "invalid token")
 		return
 	}
 
+	verdict, err := cfg.checkSpam(r.Context(), parsedID, uuid.NullUUID{}, p.Body)
+	if err != nil {
+		loggerFrom(r.Context()).Error("checking chirp for spam failed", "error", err)
+	}
+	if verdict.Spam && cfg.spam.Action == spam.ActionReject {
+		if err := cfg.handleSpam(r.Context(), parsedID, uuid.NullUUID{}, p.Body, verdict); err != nil {
+			loggerFrom(r.Context()).Error("recording spam detection failed", "error", err)
+		}
+		respondWithError(w, http.StatusBadRequest, "Chirp was rejected as spam")
+		return
+	}
+
 	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{Body: p.Body, UserID: parsedID})
 	if err != nil {
-		fmt.Println(err.Error())
+		loggerFrom(r.Context()).Error("could not create chirp at this time", "error", err)
 		respondWithError(w, http.StatusInternalServerError, "could not create chirp at this time")
 		return
 	}
+	if verdict.Spam {
+		if err := cfg.handleSpam(r.Context(), parsedID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, p.Body, verdict); err != nil {
+			loggerFrom(r.Context()).Error("recording spam detection failed", "error", err)
+		}
+	}
+	if err := recordModerationFlags(r.Context(), cfg.dbQueries, chirp.ID, moderationMatchesFromContext(r.Context())); err != nil {
+		loggerFrom(r.Context()).Error("flagging chirp for review failed", "error", err)
+	}
+	cfg.metrics.ChirpsCreated.WithLabelValues("api").Inc()
+	cfg.emitEvent(r.Context(), parsedID, webhooks.EventChirpCreated, chirp)
 	respondWithJSON(w, http.StatusCreated, map[string]string{
 		"id":         chirp.ID.String(),
 		"created_at": chirp.CreatedAt.String(),
@@ -51,37 +77,92 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// viewerID identifies the caller of an endpoint that doesn't require
+// authentication, so that shadow-banned users still see their own chirps.
+// Callers whose token validateAccessToken rejects are anonymous.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	id, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
 func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 	var chirps []database.Chirp
 	var err error
 
+	page, err := parseChirpsPage(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	newest := r.URL.Query().Get("sort") == "desc"
+
 	if authorId := r.URL.Query().Get("author_id"); authorId != "" {
 		parsed, err := uuid.Parse(authorId)
 		if err != nil {
 			respondWithError(w, http.StatusNotFound, "author not found")
+			return
 		}
-		chirps, err = cfg.dbQueries.GetAllChirpsFromAuthorID(r.Context(), parsed)
+		chirps, err = cfg.dbQueries.GetAllChirpsFromAuthorID(r.Context(), database.GetAllChirpsFromAuthorIDParams{
+			UserID:     parsed,
+			ViewerID:   cfg.viewerID(r),
+			Newest:     newest,
+			PageOffset: page.offset,
+			PageLimit:  page.limit,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps")
 			return
 		}
 	} else {
-		chirps, err = cfg.dbQueries.GetAllChirps(r.Context())
+		chirps, err = cfg.dbQueries.GetAllChirps(r.Context(), database.GetAllChirpsParams{
+			ViewerID:   cfg.viewerID(r),
+			Newest:     newest,
+			PageOffset: page.offset,
+			PageLimit:  page.limit,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps")
 			return
 		}
 	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// maxChirpsPageSize caps the limit parameter of GET /api/chirps.
+const maxChirpsPageSize = 100
 
-	if sortParam := r.URL.Query().Get("sort"); sortParam != "" {
-		if sortParam == "desc" {
-			sort.Slice(chirps, func(i, j int) bool {
-				return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-			})
+type chirpsPage struct {
+	offset int32
+	limit  sql.NullInt32
+}
+
+// parseChirpsPage reads the optional limit and offset query parameters.
+// Without a limit every chirp from offset on is returned, as before they
+// existed.
+func parseChirpsPage(query url.Values) (chirpsPage, error) {
+	var page chirpsPage
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || offset < 0 {
+			return page, errors.New("offset must be a non-negative integer")
 		}
+		page.offset = int32(offset)
 	}
-
-	respondWithJSON(w, http.StatusOK, chirps)
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxChirpsPageSize {
+			return page, errors.New("limit must be between 1 and " + strconv.Itoa(maxChirpsPageSize))
+		}
+		page.limit = sql.NullInt32{Int32: int32(limit), Valid: true}
+	}
+	return page, nil
 }
 
 func (cfg *apiConfig) getChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -91,7 +172,7 @@ func (cfg *apiConfig) getChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), id)
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), database.GetChirpByIDParams{ID: id, ViewerID: cfg.viewerID(r)})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
 		return
@@ -110,12 +191,12 @@ func (cfg *apiConfig) deleteChirpByID(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "invalid token")
 		return
 	}
-	parsedID, err := auth.ValidateJWT(bearerToken, cfg.secret)
+	parsedID, err := cfg.validateAccessToken(r.Context(), bearerToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "invalid token")
 		return
 	}
-	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), id)
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), database.GetChirpByIDParams{ID: id, ViewerID: uuid.NullUUID{UUID: parsedID, Valid: true}})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
 		return
@@ -127,7 +208,97 @@ func (cfg *apiConfig) deleteChirpByID(w http.ResponseWriter, r *http.Request) {
 	err = cfg.dbQueries.DeleteChirpByID(r.Context(), id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not delete chirp")
+		return
 	}
+	cfg.emitEvent(r.Context(), chirp.UserID, webhooks.EventChirpDeleted, chirpDeletedEvent(chirp))
 	
 	RespondNoContent(w, r);
 }
+
+// updateChirp edits the body of one of the caller's chirps. Editing is a
+// Chirpy Red perk; the new body goes through the same moderation and length
+// checks as a new chirp.
+func (cfg *apiConfig) updateChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	parsedID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	var p struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	perks, err := cfg.perksForUserID(r.Context(), parsedID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if !perks.EditChirps {
+		respondWithError(w, http.StatusForbidden, "editing chirps requires Chirpy Red")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), database.GetChirpByIDParams{ID: id, ViewerID: uuid.NullUUID{UUID: parsedID, Valid: true}})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	if chirp.UserID != parsedID {
+		respondWithError(w, http.StatusForbidden, "you are not allowed")
+		return
+	}
+
+	moderated := cfg.moderation.Check(p.Body)
+	if moderated.Rejected() {
+		respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited language")
+		return
+	}
+	if err := validateChirpBody(moderated.Body, perks.MaxChirpLength); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Edits go through the same spam checks as new chirps, so that a clean
+	// chirp can't be edited into spam afterwards.
+	verdict, err := cfg.checkSpam(r.Context(), parsedID, uuid.NullUUID{UUID: id, Valid: true}, moderated.Body)
+	if err != nil {
+		loggerFrom(r.Context()).Error("checking chirp for spam failed", "error", err)
+	}
+	if verdict.Spam && cfg.spam.Action == spam.ActionReject {
+		if err := cfg.handleSpam(r.Context(), parsedID, uuid.NullUUID{}, moderated.Body, verdict); err != nil {
+			loggerFrom(r.Context()).Error("recording spam detection failed", "error", err)
+		}
+		respondWithError(w, http.StatusBadRequest, "Chirp was rejected as spam")
+		return
+	}
+	updated, err := cfg.dbQueries.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{ID: id, Body: moderated.Body})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not update chirp", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not update chirp")
+		return
+	}
+	if verdict.Spam {
+		if err := cfg.handleSpam(r.Context(), parsedID, uuid.NullUUID{UUID: updated.ID, Valid: true}, moderated.Body, verdict); err != nil {
+			loggerFrom(r.Context()).Error("recording spam detection failed", "error", err)
+		}
+	}
+	if err := recordModerationFlags(r.Context(), cfg.dbQueries, updated.ID, moderated.Matches); err != nil {
+		loggerFrom(r.Context()).Error("flagging chirp for review failed", "error", err)
+	}
+	respondWithJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/entitlements"
+)
+
+const maxImportSize = 10 << 20
+
+// importLine is one post of a JSON Lines archive. SourceID is the post's ID
+// in the tool it was exported from and makes re-running an import a no-op.
+type importLine struct {
+	SourceID  string    `json:"source_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type importResult struct {
+	Line     int    `json:"line"`
+	SourceID string `json:"source_id,omitempty"`
+	Status   string `json:"status"`
+	ChirpID  string `json:"chirp_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type importSummary struct {
+	Imported int            `json:"imported"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Results  []importResult `json:"results"`
+}
+
+// importChirps reads a JSON Lines archive and creates a chirp for every post
+// that has not been imported for userID yet. Bodies go through the same
+// pipeline as createChirp. Problems with a single line are reported in its
+// result; the returned error is only set when the archive can't be read.
+func (cfg *apiConfig) importChirps(ctx context.Context, userID uuid.UUID, archive io.Reader) (importSummary, error) {
+	summary := importSummary{Results: []importResult{}}
+	perks, err := cfg.perksForUserID(ctx, userID)
+	if err != nil {
+		return summary, fmt.Errorf("could not look up user: %w", err)
+	}
+	scanner := bufio.NewScanner(archive)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		result := cfg.importChirp(ctx, userID, perks, lineNumber, scanner.Bytes())
+		switch result.Status {
+		case "imported":
+			summary.Imported++
+		case "skipped":
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("could not read archive after line %d: %w", lineNumber, err)
+	}
+	return summary, nil
+}
+
+func (cfg *apiConfig) importChirp(ctx context.Context, userID uuid.UUID, perks entitlements.Perks, lineNumber int, raw []byte) importResult {
+	result := importResult{Line: lineNumber, Status: "failed"}
+	var line importLine
+	if err := json.Unmarshal(raw, &line); err != nil {
+		result.Error = "could not parse line"
+		return result
+	}
+	result.SourceID = line.SourceID
+	if line.SourceID == "" {
+		result.Error = "missing source_id"
+		return result
+	}
+	if line.CreatedAt.IsZero() {
+		result.Error = "missing created_at"
+		return result
+	}
+	if line.CreatedAt.After(time.Now()) {
+		result.Error = "created_at is in the future"
+		return result
+	}
+	moderated := cfg.moderation.Check(line.Body)
+	if moderated.Rejected() {
+		result.Error = "Chirp contains prohibited language"
+		return result
+	}
+	body := moderated.Body
+	if err := validateChirpBody(body, perks.MaxChirpLength); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	existing, err := cfg.dbQueries.GetChirpImport(ctx, database.GetChirpImportParams{UserID: userID, SourceID: line.SourceID})
+	if err == nil {
+		result.Status = "skipped"
+		result.ChirpID = existing.ChirpID.String()
+		return result
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		loggerFrom(ctx).Error("could not import chirp at this time", "error", err)
+		result.Error = "could not import chirp at this time"
+		return result
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		loggerFrom(ctx).Error("could not import chirp at this time", "error", err)
+		result.Error = "could not import chirp at this time"
+		return result
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	chirp, err := qtx.CreateImportedChirp(ctx, database.CreateImportedChirpParams{
+		CreatedAt: line.CreatedAt,
+		Body:      body,
+		UserID:    userID,
+	})
+	if err != nil {
+		loggerFrom(ctx).Error("could not import chirp at this time", "error", err)
+		result.Error = "could not import chirp at this time"
+		return result
+	}
+	err = qtx.CreateChirpImport(ctx, database.CreateChirpImportParams{
+		UserID:   userID,
+		SourceID: line.SourceID,
+		ChirpID:  chirp.ID,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		// A concurrent import of the same archive got there first.
+		result.Status = "skipped"
+		return result
+	}
+	if err == nil {
+		err = recordModerationFlags(ctx, qtx, chirp.ID, moderated.Matches)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		loggerFrom(ctx).Error("could not import chirp at this time", "error", err)
+		result.Error = "could not import chirp at this time"
+		return result
+	}
+
+	cfg.metrics.ChirpsCreated.WithLabelValues("import").Inc()
+	result.Status = "imported"
+	result.ChirpID = chirp.ID.String()
+	return result
+}
+
+func (cfg *apiConfig) handleChirpImport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	defer r.Body.Close()
+	summary, err := cfg.importChirps(r.Context(), userID, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not read archive", "error", err)
+		respondWithError(w, http.StatusBadRequest, "could not read archive")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
+// runImportCommand implements `chirpy import`, which imports an archive on
+// behalf of an existing user without going through the HTTP API.
+func runImportCommand(cfg *apiConfig, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user the chirps are imported for")
+	file := fs.String("file", "-", "JSON Lines archive to import, or - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("import: -email is required")
+	}
+
+	ctx := context.Background()
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("import: could not find user %s: %w", *email, err)
+	}
+
+	archive := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		defer f.Close()
+		archive = f
+	}
+
+	summary, err := cfg.importChirps(ctx, user.ID, archive)
+	encoder := json.NewEncoder(os.Stdout)
+	for _, result := range summary.Results {
+		encoder.Encode(result)
+	}
+	fmt.Fprintf(os.Stderr, "imported %d, skipped %d, failed %d\n", summary.Imported, summary.Skipped, summary.Failed)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return nil
+}
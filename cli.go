@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/config"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/webhooks"
+)
+
+const commandUsage = `usage: chirpy [flags] [command]
+
+commands:
+  serve                              run the API server (the default)
+  config                             print the effective configuration
+  migrate up|down|status|redo        manage the database schema
+  import -email EMAIL [-file FILE]   import a chirp archive for a user
+  user create EMAIL                  create a user; the password is read from stdin
+  user reset-password USER           set a new password read from stdin and sign the user out
+  user export [-o FILE] USER         write the user's data export archive
+  user role USER ROLE                make the user a user, moderator or admin and sign them out
+  red grant USER                     give the user Chirpy Red
+  red revoke USER                    take Chirpy Red away from the user
+  sessions revoke USER               sign the user out everywhere
+  chirp delete CHIRP_ID              delete any chirp
+
+USER is an email address or a user ID. Run chirpy -h for the flags.`
+
+// adminCommands are run against the database with the same queries the
+// handlers use, keyed by command and then subcommand.
+var adminCommands = map[string]map[string]func(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error{
+	"user": {
+		"create":         createUserCommand,
+		"reset-password": resetPasswordCommand,
+		"export":         exportUserCommand,
+		"role":           userRoleCommand,
+	},
+	"red": {
+		"grant":  chirpyRedCommand(billing.EventUpgraded),
+		"revoke": chirpyRedCommand(billing.EventDowngraded),
+	},
+	"sessions": {
+		"revoke": revokeSessionsCommand,
+	},
+	"chirp": {
+		"delete": deleteChirpCommand,
+	},
+}
+
+// runCommand runs the command named by the first of args, or the server
+// when there is none.
+func runCommand(c config.Config, args []string) error {
+	if len(args) == 0 {
+		return serve(c)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "serve":
+		return serve(c)
+	case "config":
+		// Printing is most useful when the configuration doesn't validate,
+		// so it comes first.
+		if err := c.Print(os.Stdout); err != nil {
+			return err
+		}
+		return c.Validate()
+	case "migrate":
+		return withDatabase(c, func(db *sql.DB) error {
+			return runMigrateCommand(context.Background(), db, args, os.Stdout)
+		})
+	case "import":
+		return withAdmin(c, func(cfg *apiConfig) error {
+			return runImportCommand(cfg, args)
+		})
+	}
+
+	subcommands, ok := adminCommands[command]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", command, commandUsage)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs a subcommand\n\n%s", command, commandUsage)
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", command+" "+args[0], commandUsage)
+	}
+	return withAdmin(c, func(cfg *apiConfig) error {
+		if err := run(context.Background(), cfg, args[1:], os.Stdout); err != nil {
+			return fmt.Errorf("%s %s: %w", command, args[0], err)
+		}
+		return nil
+	})
+}
+
+// withAdmin sets chirpy up for a one-off command. Unlike serve, it only
+// needs the database, so a missing SECRET doesn't get in the way.
+func withAdmin(c config.Config, fn func(cfg *apiConfig) error) error {
+	if c.DatabaseURL == "" {
+		return errors.New("DB_URL is required")
+	}
+	cfg := apiConfig{}
+	if err := cfg.init(c); err != nil {
+		return fmt.Errorf("could not configure chirpy: %w", err)
+	}
+	defer cfg.db.Close()
+	defer cfg.shutdownTracing(context.Background())
+	return fn(&cfg)
+}
+
+// oneArg parses flags and returns the single positional argument.
+func oneArg(fs *flag.FlagSet, args []string, name string) (string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("expected %s", name)
+	}
+	return fs.Arg(0), nil
+}
+
+// lookupUser finds a user by ID or email.
+func (cfg *apiConfig) lookupUser(ctx context.Context, ref string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = cfg.dbQueries.GetUserByID(ctx, id)
+	} else {
+		user, err = cfg.dbQueries.GetUserByEmail(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, fmt.Errorf("could not find user %s", ref)
+	}
+	return user, err
+}
+
+// readPassword reads a password from the first line of stdin, so that it
+// stays out of the shell history.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password can't be empty")
+	}
+	return password, nil
+}
+
+func createUserCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	email, err := oneArg(flag.NewFlagSet("user create", flag.ContinueOnError), args, "an email address")
+	if err != nil {
+		return err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user, err := cfg.dbQueries.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashed})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created user %s (%s)\n", user.ID, user.Email)
+	return nil
+}
+
+// resetPasswordCommand also revokes the user's sessions, since a reset
+// usually means the old password leaked.
+func resetPasswordCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	ref, err := oneArg(flag.NewFlagSet("user reset-password", flag.ContinueOnError), args, "a user")
+	if err != nil {
+		return err
+	}
+	user, err := cfg.lookupUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = cfg.dbQueries.UpdateUserEmailAndPassword(ctx, database.UpdateUserEmailAndPasswordParams{
+		ID:             user.ID,
+		Email:          user.Email,
+		HashedPassword: hashed,
+	})
+	if err == nil {
+		err = cfg.dbQueries.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "reset the password of %s and revoked their sessions\n", user.Email)
+	return nil
+}
+
+func exportUserCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("user export", flag.ContinueOnError)
+	file := fs.String("o", "-", "file to write the archive to, or - for stdout")
+	ref, err := oneArg(fs, args, "a user")
+	if err != nil {
+		return err
+	}
+	user, err := cfg.lookupUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	archive, err := cfg.buildDataExport(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if *file == "-" {
+		_, err = out.Write(archive)
+		return err
+	}
+	if err := os.WriteFile(*file, archive, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote the export of %s to %s\n", user.Email, *file)
+	return nil
+}
+
+// userRoleCommand is also how the first admin of a new instance is made.
+// The user's sessions are revoked so that their next token carries the new
+// role.
+func userRoleCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("user role", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("expected a user and a role")
+	}
+	role := auth.Role(fs.Arg(1))
+	if !role.Valid() {
+		return fmt.Errorf("role must be one of %s, %s or %s", auth.RoleUser, auth.RoleModerator, auth.RoleAdmin)
+	}
+	user, err := cfg.lookupUser(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+	_, err = qtx.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: user.ID, Role: string(role)})
+	if err == nil {
+		err = qtx.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "set the role of %s to %s and revoked their sessions\n", user.Email, role)
+	return nil
+}
+
+// chirpyRedCommand changes a subscription the way the matching Polka
+// webhook would, so that the change shows up in the user's history.
+func chirpyRedCommand(event billing.Event) func(context.Context, *apiConfig, []string, io.Writer) error {
+	return func(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+		ref, err := oneArg(flag.NewFlagSet("red", flag.ContinueOnError), args, "a user")
+		if err != nil {
+			return err
+		}
+		user, err := cfg.lookupUser(ctx, ref)
+		if err != nil {
+			return err
+		}
+		tx, err := cfg.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		err = cfg.applySubscriptionEvent(ctx, cfg.withTx(tx), uuid.NullUUID{}, user.ID, event)
+		if errors.Is(err, billing.ErrInvalidTransition) {
+			return fmt.Errorf("%s doesn't apply to the subscription of %s", event, user.Email)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %s to %s\n", event, user.Email)
+		return nil
+	}
+}
+
+func revokeSessionsCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	ref, err := oneArg(flag.NewFlagSet("sessions revoke", flag.ContinueOnError), args, "a user")
+	if err != nil {
+		return err
+	}
+	user, err := cfg.lookupUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := cfg.dbQueries.RevokeAllRefreshTokensForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "revoked every session of %s\n", user.Email)
+	return nil
+}
+
+func deleteChirpCommand(ctx context.Context, cfg *apiConfig, args []string, out io.Writer) error {
+	raw, err := oneArg(flag.NewFlagSet("chirp delete", flag.ContinueOnError), args, "a chirp ID")
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid chirp ID %q", raw)
+	}
+	chirp, err := cfg.dbQueries.DeleteChirpByIDReturning(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not find chirp %s", id)
+	}
+	if err != nil {
+		return err
+	}
+	cfg.emitEvent(ctx, chirp.UserID, webhooks.EventChirpDeleted, chirpDeletedEvent(chirp))
+	fmt.Fprintf(out, "deleted chirp %s\n", chirp.ID)
+	return nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Chirp matches the server's database.Chirp.
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+func (ch *Chirp) UnmarshalJSON(data []byte) error {
+	type plain Chirp
+	var raw struct {
+		plain
+		CreatedAt timestamp `json:"created_at"`
+		UpdatedAt timestamp `json:"updated_at"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*ch = Chirp(raw.plain)
+	ch.CreatedAt, ch.UpdatedAt = raw.CreatedAt.Time, raw.UpdatedAt.Time
+	return nil
+}
+
+// CreateChirp posts a chirp as the signed-in user.
+func (c *Client) CreateChirp(ctx context.Context, body string) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, http.MethodPost, "/api/chirps", accessToken, map[string]string{"body": body}, &chirp)
+	return chirp, err
+}
+
+// GetChirp fetches a single chirp.
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, http.MethodGet, "/api/chirps/"+id.String(), accessToken, nil, &chirp)
+	return chirp, err
+}
+
+// UpdateChirp replaces the body of one of the signed-in user's chirps.
+func (c *Client) UpdateChirp(ctx context.Context, id uuid.UUID, body string) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, http.MethodPut, "/api/chirps/"+id.String(), accessToken, map[string]string{"body": body}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes one of the signed-in user's chirps.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/api/chirps/"+id.String(), accessToken, nil, nil)
+}
+
+// DefaultPageSize is the number of chirps Chirps fetches per request when
+// ListOptions.Limit isn't set. It is also the most the server returns.
+const DefaultPageSize = 100
+
+// ListOptions filter and page GET /api/chirps.
+type ListOptions struct {
+	// AuthorID only lists the chirps of one user.
+	AuthorID uuid.UUID
+	// Newest lists the newest chirps first instead of the oldest.
+	Newest bool
+	Offset int
+	// Limit is the page size. Zero lets the server return every chirp.
+	Limit int
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.AuthorID != uuid.Nil {
+		query.Set("author_id", o.AuthorID.String())
+	}
+	if o.Newest {
+		query.Set("sort", "desc")
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListChirps fetches a single page of chirps.
+func (c *Client) ListChirps(ctx context.Context, opts ListOptions) ([]Chirp, error) {
+	path := "/api/chirps"
+	if query := opts.query().Encode(); query != "" {
+		path += "?" + query
+	}
+	var chirps []Chirp
+	err := c.do(ctx, http.MethodGet, path, accessToken, nil, &chirps)
+	return chirps, err
+}
+
+// Chirps iterates over every chirp matching opts, fetching a page of
+// opts.Limit chirps at a time from opts.Offset on. Iteration stops at the
+// first error, which is yielded with a zero Chirp.
+func (c *Client) Chirps(ctx context.Context, opts ListOptions) iter.Seq2[Chirp, error] {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	return func(yield func(Chirp, error) bool) {
+		for {
+			page, err := c.ListChirps(ctx, opts)
+			if err != nil {
+				yield(Chirp{}, err)
+				return
+			}
+			for _, chirp := range page {
+				if !yield(chirp, nil) {
+					return
+				}
+			}
+			if len(page) < opts.Limit {
+				return
+			}
+			opts.Offset += len(page)
+		}
+	}
+}
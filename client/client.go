@@ -0,0 +1,243 @@
+// Package client is a Go client for the Chirpy API.
+//
+// A Client signs in with Login, keeps the access and refresh tokens it gets
+// back, and refreshes the access token by itself when the server answers a
+// request with 401. Errors reported by the server come back as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are the credentials of a signed-in client.
+type Tokens struct {
+	Access  string `json:"token"`
+	Refresh string `json:"refresh_token"`
+}
+
+// Client talks to a single Chirpy server. It is safe for concurrent use.
+type Client struct {
+	baseURL        string
+	httpClient     *http.Client
+	onTokenRefresh func(Tokens)
+
+	mu     sync.Mutex
+	tokens Tokens
+	// refreshMu is held for the whole of a refresh, so that concurrent
+	// requests rejected with the same access token only refresh it once.
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTokens starts the client signed in, typically with tokens saved from
+// an earlier Login.
+func WithTokens(tokens Tokens) Option {
+	return func(c *Client) { c.tokens = tokens }
+}
+
+// OnTokenRefresh calls fn with the new tokens whenever the client signs in
+// or refreshes its access token, so that callers can persist them.
+func OnTokenRefresh(fn func(Tokens)) Option {
+	return func(c *Client) { c.onTokenRefresh = fn }
+}
+
+// New returns a client for the server at baseURL, e.g.
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the client's current credentials.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+func (c *Client) setTokens(tokens Tokens) {
+	c.mu.Lock()
+	c.tokens = tokens
+	c.mu.Unlock()
+	if c.onTokenRefresh != nil {
+		c.onTokenRefresh(tokens)
+	}
+}
+
+// Error is an error response from the server, built from its
+// {"error": "..."} envelope.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is how long the server asked to wait before trying again,
+	// when it rate limited the request.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chirpy: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("chirpy: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether target is one of the status errors below with the
+// same status code, so that errors.Is(err, client.ErrNotFound) works.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.StatusCode == e.StatusCode
+}
+
+var (
+	ErrBadRequest   = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized = &Error{StatusCode: http.StatusUnauthorized}
+	ErrForbidden    = &Error{StatusCode: http.StatusForbidden}
+	ErrNotFound     = &Error{StatusCode: http.StatusNotFound}
+	ErrRateLimited  = &Error{StatusCode: http.StatusTooManyRequests}
+)
+
+// ErrNotLoggedIn is returned by calls that need a refresh token when the
+// client has none.
+var ErrNotLoggedIn = errors.New("chirpy: not logged in")
+
+func errorFromResponse(res *http.Response) error {
+	e := &Error{StatusCode: res.StatusCode}
+	var envelope struct {
+		Error string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != "" {
+		e.Message = envelope.Error
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	if seconds, err := time.ParseDuration(res.Header.Get("Retry-After") + "s"); err == nil {
+		e.RetryAfter = seconds
+	}
+	return e
+}
+
+// auth says which token, if any, a request is sent with.
+type auth int
+
+const (
+	noAuth auth = iota
+	accessToken
+	refreshToken
+)
+
+// do sends a JSON request and decodes the response into out, unless out is
+// nil. Requests made with the access token are retried once with a fresh
+// one when the server rejects it.
+func (c *Client) do(ctx context.Context, method, path string, authWith auth, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	tokens := c.Tokens()
+	res, err := c.send(ctx, method, path, authWith, tokens, body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusUnauthorized && authWith == accessToken && tokens.Refresh != "" {
+		res.Body.Close()
+		if err := c.refreshAfter(ctx, tokens.Access); err != nil {
+			return err
+		}
+		if res, err = c.send(ctx, method, path, authWith, c.Tokens(), body); err != nil {
+			return err
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return errorFromResponse(res)
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("chirpy: could not decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, authWith auth, tokens Tokens, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case authWith == accessToken && tokens.Access != "":
+		req.Header.Set("Authorization", "Bearer "+tokens.Access)
+	case authWith == refreshToken:
+		req.Header.Set("Authorization", "Bearer "+tokens.Refresh)
+	}
+	return c.httpClient.Do(req)
+}
+
+// refreshAfter refreshes the access token unless another request already
+// replaced stale while this one was waiting for the lock.
+func (c *Client) refreshAfter(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.Tokens().Access != stale {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+// Refresh exchanges the refresh token for a new access token.
+func (c *Client) Refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.refresh(ctx)
+}
+
+func (c *Client) refresh(ctx context.Context) error {
+	tokens := c.Tokens()
+	if tokens.Refresh == "" {
+		return ErrNotLoggedIn
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/refresh", refreshToken, nil, &res); err != nil {
+		return err
+	}
+	tokens.Access = res.Token
+	c.setTokens(tokens)
+	return nil
+}
@@ -0,0 +1,213 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/client"
+)
+
+func writeJSON(w http.ResponseWriter, code int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func TestLoginKeepsTokens(t *testing.T) {
+	created := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var creds client.Credentials
+		json.NewDecoder(r.Body).Decode(&creds)
+		if r.URL.Path != "/api/login" || creds.Email != "walt@example.com" || creds.Password != "04234" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Incorrect email or password"})
+			return
+		}
+		// The server formats these with time.Time.String.
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":            uuid.NewString(),
+			"created_at":    created.String(),
+			"updated_at":    created.String(),
+			"email":         creds.Email,
+			"token":         "access",
+			"refresh_token": "refresh",
+			"is_chirpy_red": true,
+		})
+	}))
+	defer server.Close()
+
+	var saved client.Tokens
+	c := client.New(server.URL, client.OnTokenRefresh(func(tokens client.Tokens) { saved = tokens }))
+	user, err := c.Login(context.Background(), client.Credentials{Email: "walt@example.com", Password: "04234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "walt@example.com" || !user.IsChirpyRed || !user.CreatedAt.Equal(created) {
+		t.Errorf("unexpected user %+v", user)
+	}
+	expected := client.Tokens{Access: "access", Refresh: "refresh"}
+	if c.Tokens() != expected || saved != expected {
+		t.Errorf("expected tokens %+v to be kept and reported, got %+v and %+v", expected, c.Tokens(), saved)
+	}
+
+	_, err = c.Login(context.Background(), client.Credentials{Email: "walt@example.com", Password: "wrong"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Message != "Incorrect email or password" {
+		t.Errorf("expected the error envelope to be decoded, got %v", err)
+	}
+	if !errors.Is(err, client.ErrUnauthorized) || errors.Is(err, client.ErrForbidden) {
+		t.Errorf("expected err to match only ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestRefreshesOnUnauthorized(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/refresh":
+			if r.Header.Get("Authorization") != "Bearer refresh" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+				return
+			}
+			refreshes.Add(1)
+			writeJSON(w, http.StatusOK, map[string]string{"token": "fresh"})
+		case r.Header.Get("Authorization") != "Bearer fresh":
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+		default:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			writeJSON(w, http.StatusCreated, map[string]string{"id": uuid.NewString(), "body": body["body"]})
+		}
+	}))
+	defer server.Close()
+
+	var saved client.Tokens
+	c := client.New(server.URL,
+		client.WithHTTPClient(server.Client()),
+		client.WithTokens(client.Tokens{Access: "expired", Refresh: "refresh"}),
+		client.OnTokenRefresh(func(tokens client.Tokens) { saved = tokens }),
+	)
+	chirp, err := c.CreateChirp(context.Background(), "I'm the one who knocks!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.Body != "I'm the one who knocks!" {
+		t.Errorf("expected the request body to be resent, got %q", chirp.Body)
+	}
+	if refreshes.Load() != 1 || saved.Access != "fresh" || saved.Refresh != "refresh" {
+		t.Errorf("expected one refresh to be saved, got %d and %+v", refreshes.Load(), saved)
+	}
+
+	c = client.New(server.URL, client.WithTokens(client.Tokens{Access: "expired", Refresh: "revoked"}))
+	if _, err := c.CreateChirp(context.Background(), "hi"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected a failed refresh to be returned, got %v", err)
+	}
+}
+
+func TestConcurrentRequestsRefreshOnce(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/refresh":
+			// Give the other requests time to be rejected too.
+			time.Sleep(20 * time.Millisecond)
+			writeJSON(w, http.StatusOK, map[string]string{"token": fmt.Sprintf("fresh-%d", refreshes.Add(1))})
+		case r.Header.Get("Authorization") == "Bearer expired":
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+		default:
+			writeJSON(w, http.StatusCreated, map[string]string{"id": uuid.NewString()})
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithTokens(client.Tokens{Access: "expired", Refresh: "refresh"}))
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.CreateChirp(context.Background(), "Say my name.")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if refreshes.Load() != 1 || c.Tokens().Access != "fresh-1" {
+		t.Errorf("expected a single refresh, got %d and %+v", refreshes.Load(), c.Tokens())
+	}
+}
+
+func TestChirpsPages(t *testing.T) {
+	const total = 7
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if r.URL.Query().Get("sort") != "desc" {
+			t.Errorf("expected sort=desc, got %q", r.URL.RawQuery)
+		}
+		chirps := []map[string]string{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			chirps = append(chirps, map[string]string{
+				"id":         uuid.NewString(),
+				"created_at": time.Now().UTC().Format(time.RFC3339Nano),
+				"body":       fmt.Sprintf("chirp %d", i),
+			})
+		}
+		writeJSON(w, http.StatusOK, chirps)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL)
+	var bodies []string
+	for chirp, err := range c.Chirps(context.Background(), client.ListOptions{Newest: true, Limit: 3}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, chirp.Body)
+	}
+	if len(bodies) != total || bodies[total-1] != "chirp 6" {
+		t.Errorf("expected every chirp in order, got %q", bodies)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected 3 pages, got %d requests", requests.Load())
+	}
+
+	requests.Store(0)
+	for range c.Chirps(context.Background(), client.ListOptions{Newest: true, Limit: 3}) {
+		break
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected breaking out to stop paging, got %d requests", requests.Load())
+	}
+}
+
+func TestRateLimitedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+	}))
+	defer server.Close()
+
+	_, err := client.New(server.URL).GetChirp(context.Background(), uuid.New())
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != 12*time.Second {
+		t.Errorf("expected a rate limit error with Retry-After, got %v", err)
+	}
+}
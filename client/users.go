@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is the account payload returned by /api/users and /api/login.
+type User struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func (u *User) UnmarshalJSON(data []byte) error {
+	type plain User
+	var raw struct {
+		plain
+		CreatedAt timestamp `json:"created_at"`
+		UpdatedAt timestamp `json:"updated_at"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*u = User(raw.plain)
+	u.CreatedAt, u.UpdatedAt = raw.CreatedAt.Time, raw.UpdatedAt.Time
+	return nil
+}
+
+// Credentials are sent to sign up, sign in and change the email address or
+// password.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// CreateUser signs up a new user. It doesn't sign the client in.
+func (c *Client) CreateUser(ctx context.Context, creds Credentials) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodPost, "/api/users", noAuth, creds, &user)
+	return user, err
+}
+
+// Login signs in and keeps the tokens for later requests.
+func (c *Client) Login(ctx context.Context, creds Credentials) (User, error) {
+	// The response is a user with the tokens alongside, decoded in two
+	// passes because User has its own UnmarshalJSON.
+	var raw json.RawMessage
+	if err := c.do(ctx, http.MethodPost, "/api/login", noAuth, creds, &raw); err != nil {
+		return User{}, err
+	}
+	var user User
+	var tokens Tokens
+	if err := json.Unmarshal(raw, &user); err != nil {
+		return User{}, err
+	}
+	if err := json.Unmarshal(raw, &tokens); err != nil {
+		return User{}, err
+	}
+	c.setTokens(tokens)
+	return user, nil
+}
+
+// UpdateUser changes the signed-in user's email address and password.
+func (c *Client) UpdateUser(ctx context.Context, creds Credentials) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodPut, "/api/users", accessToken, creds, &user)
+	return user, err
+}
+
+// Logout revokes the refresh token and forgets both tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	if c.Tokens().Refresh == "" {
+		return ErrNotLoggedIn
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/revoke", refreshToken, nil, nil); err != nil {
+		return err
+	}
+	c.setTokens(Tokens{})
+	return nil
+}
+
+// timestamp accepts both RFC 3339 and the time.Time.String format, which
+// some endpoints respond with.
+type timestamp struct {
+	time.Time
+}
+
+func (t *timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		t.Time = parsed
+		return nil
+	}
+	// Times that came from time.Now carry a monotonic clock reading.
+	s, _, _ = strings.Cut(s, " m=")
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
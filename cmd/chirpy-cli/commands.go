@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/client"
+)
+
+// parseArgs parses flags and checks the number of positional arguments,
+// named by names.
+func parseArgs(fs *flag.FlagSet, args []string, names ...string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != len(names) {
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		}
+		return nil, fmt.Errorf("expected %s", strings.Join(names, " "))
+	}
+	return fs.Args(), nil
+}
+
+func parseChirpID(raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return id, fmt.Errorf("invalid chirp ID %q", raw)
+	}
+	return id, nil
+}
+
+// authorFlag adds -author, which filters chirps by user ID.
+func authorFlag(fs *flag.FlagSet) func() (uuid.UUID, error) {
+	raw := fs.String("author", "", "")
+	return func() (uuid.UUID, error) {
+		if *raw == "" {
+			return uuid.Nil, nil
+		}
+		id, err := uuid.Parse(*raw)
+		if err != nil {
+			return id, fmt.Errorf("invalid author ID %q", *raw)
+		}
+		return id, nil
+	}
+}
+
+// readLine reads the first line of stdin, so that passwords stay out of the
+// shell history.
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func loginCommand(ctx context.Context, a *app, args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("login", flag.ContinueOnError), args, "EMAIL")
+	if err != nil {
+		return err
+	}
+	password, err := readLine("password: ")
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password can't be empty")
+	}
+	a.creds.Email = positional[0]
+	user, err := a.client.Login(ctx, client.Credentials{Email: positional[0], Password: password})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "logged in to %s as %s\n", a.creds.Server, user.Email)
+	return nil
+}
+
+// logoutCommand forgets the tokens even when the server no longer accepts
+// them, since there is nothing left to revoke then.
+func logoutCommand(ctx context.Context, a *app, args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("logout", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	err := a.client.Logout(ctx)
+	if errors.Is(err, client.ErrNotLoggedIn) {
+		return errors.New("not logged in")
+	}
+	if err != nil && !errors.Is(err, client.ErrUnauthorized) {
+		return err
+	}
+	a.creds.Email = ""
+	a.saveTokens(client.Tokens{})
+	fmt.Fprintf(os.Stderr, "logged out of %s\n", a.creds.Server)
+	return nil
+}
+
+func postCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("post", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	body := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 || body == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		body = strings.TrimSpace(string(data))
+	}
+	if body == "" {
+		return errors.New("chirp can't be empty")
+	}
+	chirp, err := a.client.CreateChirp(ctx, body)
+	if err != nil {
+		return err
+	}
+	return a.printer.chirp(chirp)
+}
+
+func timelineCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("timeline", flag.ContinueOnError)
+	n := fs.Int("n", 20, "")
+	oldest := fs.Bool("oldest", false, "")
+	author := authorFlag(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	authorID, err := author()
+	if err != nil {
+		return err
+	}
+	opts := client.ListOptions{AuthorID: authorID, Newest: !*oldest}
+	if *n > 0 && *n < client.DefaultPageSize {
+		opts.Limit = *n
+	}
+
+	var chirps []client.Chirp
+	for chirp, err := range a.client.Chirps(ctx, opts) {
+		if err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+		if len(chirps) == *n {
+			break
+		}
+	}
+	return a.printer.chirps(chirps)
+}
+
+func getCommand(ctx context.Context, a *app, args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, "CHIRP_ID")
+	if err != nil {
+		return err
+	}
+	id, err := parseChirpID(positional[0])
+	if err != nil {
+		return err
+	}
+	chirp, err := a.client.GetChirp(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.printer.chirp(chirp)
+}
+
+func deleteCommand(ctx context.Context, a *app, args []string) error {
+	positional, err := parseArgs(flag.NewFlagSet("delete", flag.ContinueOnError), args, "CHIRP_ID")
+	if err != nil {
+		return err
+	}
+	id, err := parseChirpID(positional[0])
+	if err != nil {
+		return err
+	}
+	if err := a.client.DeleteChirp(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "deleted chirp %s\n", id)
+	return nil
+}
+
+// tailCommand prints the latest chirps and then polls for new ones until it
+// is interrupted. Errors that may go away, like being rate limited, are
+// reported and retried on the next poll.
+func tailCommand(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	n := fs.Int("n", 10, "")
+	interval := fs.Duration("interval", 5*time.Second, "")
+	author := authorFlag(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *n < 0 || *n > client.DefaultPageSize {
+		return fmt.Errorf("-n must be between 0 and %d", client.DefaultPageSize)
+	}
+	if *interval < time.Second {
+		return errors.New("interval must be at least 1s")
+	}
+	authorID, err := author()
+	if err != nil {
+		return err
+	}
+	t := newTailer(a, client.ListOptions{AuthorID: authorID, Newest: true})
+	if err := t.start(ctx, *n); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+
+		fresh, err := t.poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var apiErr *client.Error
+		switch {
+		case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrForbidden):
+			return err
+		case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+			fmt.Fprintln(os.Stderr, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(apiErr.RetryAfter):
+			}
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err)
+		}
+		for _, chirp := range fresh {
+			if err := t.show(chirp, false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tailer keeps track of the chirps tail has printed.
+type tailer struct {
+	a    *app
+	opts client.ListOptions
+	// since is the creation time of the newest chirp printed so far, and
+	// seen holds the chirps created at that time, which every poll returns
+	// again.
+	since time.Time
+	seen  map[uuid.UUID]bool
+}
+
+func newTailer(a *app, opts client.ListOptions) *tailer {
+	return &tailer{a: a, opts: opts, seen: map[uuid.UUID]bool{}}
+}
+
+// start prints the latest n chirps. The newest chirp is fetched even when n
+// is 0, to know where to start.
+func (t *tailer) start(ctx context.Context, n int) error {
+	opts := t.opts
+	opts.Limit = max(n, 1)
+	backlog, err := t.a.client.ListChirps(ctx, opts)
+	if err != nil {
+		return err
+	}
+	slices.Reverse(backlog)
+	for _, chirp := range backlog {
+		if err := t.show(chirp, n == 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poll returns the chirps posted since the last one shown, oldest first.
+// If listing them fails part way nothing is returned, so that the next poll
+// fetches them again instead of skipping the ones before the failure.
+func (t *tailer) poll(ctx context.Context) ([]client.Chirp, error) {
+	var fresh []client.Chirp
+	for chirp, err := range t.a.client.Chirps(ctx, t.opts) {
+		if err != nil {
+			return nil, err
+		}
+		if chirp.CreatedAt.Before(t.since) {
+			break
+		}
+		if !t.seen[chirp.ID] {
+			fresh = append(fresh, chirp)
+		}
+	}
+	slices.Reverse(fresh)
+	return fresh, nil
+}
+
+// show prints chirp unless quiet and moves since forward.
+func (t *tailer) show(chirp client.Chirp, quiet bool) error {
+	if chirp.CreatedAt.After(t.since) {
+		t.since = chirp.CreatedAt
+		clear(t.seen)
+	}
+	t.seen[chirp.ID] = true
+	if quiet {
+		return nil
+	}
+	return t.a.printer.chirp(chirp)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/client"
+)
+
+// fakeServer serves GET /api/chirps from memory.
+type fakeServer struct {
+	mu     sync.Mutex
+	chirps []client.Chirp // oldest first
+	// failFrom makes pages starting at that offset fail, when positive.
+	failFrom int
+}
+
+func (s *fakeServer) post(body string, createdAt time.Time) client.Chirp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp := client.Chirp{ID: uuid.New(), CreatedAt: createdAt, UpdatedAt: createdAt, Body: body, UserID: uuid.New()}
+	s.chirps = append(s.chirps, chirp)
+	return chirp
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := r.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if s.failFrom > 0 && offset >= s.failFrom {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not retrieve chirps"})
+		return
+	}
+	chirps := slices.Clone(s.chirps)
+	if query.Get("sort") == "desc" {
+		slices.Reverse(chirps)
+	}
+	chirps = chirps[min(offset, len(chirps)):]
+	if limit, _ := strconv.Atoi(query.Get("limit")); limit > 0 && limit < len(chirps) {
+		chirps = chirps[:limit]
+	}
+	res := make([]map[string]string, 0, len(chirps))
+	for _, chirp := range chirps {
+		res = append(res, map[string]string{
+			"id":         chirp.ID.String(),
+			"created_at": chirp.CreatedAt.Format(time.RFC3339Nano),
+			"updated_at": chirp.UpdatedAt.Format(time.RFC3339Nano),
+			"body":       chirp.Body,
+			"user_id":    chirp.UserID.String(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func newTestTailer(t *testing.T, s *fakeServer, pageSize int) (*tailer, *bytes.Buffer) {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	var out bytes.Buffer
+	a := &app{client: client.New(server.URL), printer: printer{out: &out, format: "plain"}}
+	return newTailer(a, client.ListOptions{Newest: true, Limit: pageSize}), &out
+}
+
+func bodies(chirps []client.Chirp) []string {
+	var res []string
+	for _, chirp := range chirps {
+		res = append(res, chirp.Body)
+	}
+	return res
+}
+
+func TestTailShowsEachChirpOnce(t *testing.T) {
+	s := &fakeServer{}
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	s.post("old", start.Add(-time.Minute))
+	s.post("latest", start)
+	tail, out := newTestTailer(t, s, 2)
+
+	if err := tail.start(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "latest") || strings.Contains(got, "old") {
+		t.Errorf("expected only the latest chirp to be printed, got %q", got)
+	}
+
+	// A chirp created at the same time as the last one shown still counts
+	// as new.
+	s.post("same time", start)
+	s.post("first", start.Add(time.Second))
+	s.post("second", start.Add(2*time.Second))
+	fresh, err := tail.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bodies(fresh), []string{"same time", "first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	for _, chirp := range fresh {
+		if err := tail.show(chirp, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fresh, err = tail.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 0 {
+		t.Errorf("expected nothing new, got %q", bodies(fresh))
+	}
+}
+
+func TestTailRetriesAfterFailedPoll(t *testing.T) {
+	s := &fakeServer{}
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	s.post("latest", start)
+	tail, _ := newTestTailer(t, s, 2)
+	if err := tail.start(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		s.post("new "+strconv.Itoa(i), start.Add(time.Duration(i)*time.Second))
+	}
+	// The first page holds the two newest chirps; the one after fails.
+	s.failFrom = 2
+	fresh, err := tail.poll(context.Background())
+	if err == nil || fresh != nil {
+		t.Fatalf("expected the failed poll to return nothing, got %q and %v", bodies(fresh), err)
+	}
+	if !tail.since.Equal(start) {
+		t.Errorf("expected since to stay at %v, got %v", start, tail.since)
+	}
+
+	s.failFrom = 0
+	fresh, err = tail.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bodies(fresh), []string{"new 1", "new 2", "new 3"}; !slices.Equal(got, want) {
+		t.Errorf("expected the next poll to return %q, got %q", want, got)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	s := &fakeServer{}
+	created := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	chirp := s.post("Say\nmy name.", created)
+	server := httptest.NewServer(s)
+	defer server.Close()
+	config := t.TempDir() + "/credentials.yaml"
+
+	createdAt := created.Local().Format(time.DateTime)
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"table", []string{"ID", "BODY", chirp.ID.String(), createdAt, "Say my name."}},
+		{"plain", []string{createdAt + " " + chirp.UserID.String() + ": Say my name.\n"}},
+		{"json", []string{`"id":"` + chirp.ID.String() + `"`, `"body":"Say\nmy name."`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(context.Background(), []string{"-config", config, "-server", server.URL, "-output", tt.format, "timeline"}, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected %q in\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-config", config, "-server", server.URL, "-output", "json", "timeline"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []client.Chirp
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 1 || decoded[0].ID != chirp.ID {
+		t.Errorf("expected a JSON array with the chirp, got %q (%v)", out.String(), err)
+	}
+
+	if err := run(context.Background(), []string{"-config", config, "-output", "yaml", "timeline"}, &out); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
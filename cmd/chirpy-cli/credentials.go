@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/wilgnert/chirpy/client"
+	"gopkg.in/yaml.v3"
+)
+
+// credentials are what login leaves behind for the other commands.
+type credentials struct {
+	Server       string `yaml:"server"`
+	Email        string `yaml:"email,omitempty"`
+	Token        string `yaml:"token,omitempty"`
+	RefreshToken string `yaml:"refresh_token,omitempty"`
+}
+
+func (c credentials) tokens() client.Tokens {
+	return client.Tokens{Access: c.Token, Refresh: c.RefreshToken}
+}
+
+// defaultCredentialsFile is chirpy/credentials.yaml in the user's config
+// directory, e.g. ~/.config on Linux.
+func defaultCredentialsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "chirpy-credentials.yaml"
+	}
+	return filepath.Join(dir, "chirpy", "credentials.yaml")
+}
+
+// loadCredentials reads path, treating a missing file as signed out.
+func loadCredentials(path string) (credentials, error) {
+	var creds credentials
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return creds, err
+	}
+	err = yaml.Unmarshal(data, &creds)
+	return creds, err
+}
+
+// saveCredentials writes the file readable by its owner only, since the
+// refresh token is as good as the password for a couple of months.
+func saveCredentials(path string, creds credentials) error {
+	data, err := yaml.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
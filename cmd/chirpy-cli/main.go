@@ -0,0 +1,134 @@
+// Command chirpy-cli posts and reads chirps from a terminal.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/wilgnert/chirpy/client"
+)
+
+const usage = `usage: chirpy-cli [flags] command
+
+commands:
+  login EMAIL                        sign in; the password is read from stdin
+  logout                             sign out and revoke the refresh token
+  post [TEXT...]                     post a chirp, read from stdin without TEXT
+  timeline [-n N] [-author ID] [-oldest]
+                                     list chirps, newest first
+  get CHIRP_ID                       show a chirp
+  delete CHIRP_ID                    delete one of your chirps
+  tail [-n N] [-author ID] [-interval D]
+                                     print new chirps as they are posted
+
+flags:
+  -server URL      the Chirpy server (CHIRPY_URL, or the one you logged in to)
+  -output FORMAT   table, json or plain (default table)
+  -config FILE     where credentials are kept (CHIRPY_CLI_CONFIG)`
+
+const defaultServer = "http://localhost:8080"
+
+// app is what every command gets to work with.
+type app struct {
+	client    *client.Client
+	printer   printer
+	creds     credentials
+	credsFile string
+}
+
+var commands = map[string]func(ctx context.Context, a *app, args []string) error{
+	"login":    loginCommand,
+	"logout":   logoutCommand,
+	"post":     postCommand,
+	"timeline": timelineCommand,
+	"get":      getCommand,
+	"delete":   deleteCommand,
+	"tail":     tailCommand,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout)
+	stop()
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, usage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("chirpy-cli", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	credsFile := fs.String("config", envOr("CHIRPY_CLI_CONFIG", defaultCredentialsFile()), "")
+	server := fs.String("server", os.Getenv("CHIRPY_URL"), "")
+	format := fs.String("output", "table", "")
+	fs.StringVar(format, "o", "table", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !validFormat(*format) {
+		return fmt.Errorf("unknown output format %q, expected one of %s", *format, strings.Join(outputFormats, ", "))
+	}
+	if fs.NArg() == 0 {
+		return flag.ErrHelp
+	}
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", fs.Arg(0), usage)
+	}
+
+	creds, err := loadCredentials(*credsFile)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", *credsFile, err)
+	}
+	a := &app{
+		printer:   printer{out: out, format: *format},
+		creds:     creds,
+		credsFile: *credsFile,
+	}
+	if *server == "" {
+		*server = creds.Server
+	}
+	if *server == "" {
+		*server = defaultServer
+	}
+	// Tokens only work with the server that issued them.
+	var opts []client.Option
+	if *server == creds.Server {
+		opts = append(opts, client.WithTokens(creds.tokens()))
+	}
+	a.creds.Server = *server
+	a.client = client.New(*server, append(opts, client.OnTokenRefresh(a.saveTokens))...)
+
+	if err := command(ctx, a, fs.Args()[1:]); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return nil
+}
+
+// saveTokens keeps refreshed tokens, so that the next command doesn't have
+// to refresh them again.
+func (a *app) saveTokens(tokens client.Tokens) {
+	a.creds.Token, a.creds.RefreshToken = tokens.Access, tokens.Refresh
+	if err := saveCredentials(a.credsFile, a.creds); err != nil {
+		fmt.Fprintf(os.Stderr, "could not save credentials to %s: %v\n", a.credsFile, err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
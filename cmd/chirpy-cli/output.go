@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wilgnert/chirpy/client"
+)
+
+// printer writes chirps in the format picked with --output.
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+var outputFormats = []string{"table", "json", "plain"}
+
+func validFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// chirps prints a list: a table with a header, a JSON array, or one chirp
+// per line.
+func (p printer) chirps(chirps []client.Chirp) error {
+	switch p.format {
+	case "json":
+		if chirps == nil {
+			chirps = []client.Chirp{}
+		}
+		return p.json(chirps)
+	case "table":
+		w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tAUTHOR\tBODY")
+		for _, chirp := range chirps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", chirp.ID, chirp.CreatedAt.Local().Format(time.DateTime), chirp.UserID, oneLine(chirp.Body))
+		}
+		return w.Flush()
+	default:
+		for _, chirp := range chirps {
+			if err := p.plain(chirp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// chirp prints a single chirp. tail uses it too, so JSON comes out as one
+// object per line.
+func (p printer) chirp(chirp client.Chirp) error {
+	switch p.format {
+	case "json":
+		return p.json(chirp)
+	case "table":
+		w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\t%s\n", chirp.ID)
+		fmt.Fprintf(w, "CREATED\t%s\n", chirp.CreatedAt.Local().Format(time.DateTime))
+		if !chirp.UpdatedAt.Equal(chirp.CreatedAt) && !chirp.UpdatedAt.IsZero() {
+			fmt.Fprintf(w, "UPDATED\t%s\n", chirp.UpdatedAt.Local().Format(time.DateTime))
+		}
+		fmt.Fprintf(w, "AUTHOR\t%s\n", chirp.UserID)
+		fmt.Fprintf(w, "BODY\t%s\n", oneLine(chirp.Body))
+		return w.Flush()
+	default:
+		return p.plain(chirp)
+	}
+}
+
+func (p printer) plain(chirp client.Chirp) error {
+	_, err := fmt.Fprintf(p.out, "%s %s: %s\n", chirp.CreatedAt.Local().Format(time.DateTime), chirp.UserID, oneLine(chirp.Body))
+	return err
+}
+
+func (p printer) json(v any) error {
+	return json.NewEncoder(p.out).Encode(v)
+}
+
+// oneLine keeps multi-line chirps from breaking up tables.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/entitlements"
+)
+
+func isChirpyRed(expiresAt sql.NullTime) bool {
+	return expiresAt.Valid && time.Now().Before(expiresAt.Time)
+}
+
+func (cfg *apiConfig) perksFor(user database.User) entitlements.Perks {
+	return cfg.entitlements.For(entitlements.TierOf(isChirpyRed(user.ChirpyRedExpiresAt)))
+}
+
+func (cfg *apiConfig) perksForUserID(ctx context.Context, userID uuid.UUID) (entitlements.Perks, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Perks{}, err
+	}
+	return cfg.perksFor(user), nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/export"
+)
+
+const exportLinkLifetime = 15 * time.Minute
+
+func exportResource(id uuid.UUID) string {
+	return "data_exports/" + id.String()
+}
+
+func (cfg *apiConfig) requestDataExport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	dataExport, err := cfg.dbQueries.CreateDataExport(r.Context(), userID)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not request export at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not request export at this time")
+		return
+	}
+
+	_, err = cfg.jobs.Enqueue(r.Context(), jobGenerateDataExport, generateDataExportJob{ExportID: dataExport.ID, UserID: userID})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not request export at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not request export at this time")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]any{
+		"id":         dataExport.ID.String(),
+		"created_at": dataExport.CreatedAt.String(),
+		"status":     dataExport.Status,
+	})
+}
+
+func (cfg *apiConfig) getDataExport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve export")
+		return
+	}
+	dataExport, err := cfg.dbQueries.GetDataExport(r.Context(), id)
+	if err != nil || dataExport.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "could not retrieve export")
+		return
+	}
+
+	res := map[string]any{
+		"id":         dataExport.ID.String(),
+		"created_at": dataExport.CreatedAt.String(),
+		"status":     dataExport.Status,
+	}
+	switch dataExport.Status {
+	case "ready":
+		expiresAt := time.Now().Add(exportLinkLifetime)
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+		query.Set("signature", auth.SignResource(exportResource(dataExport.ID), expiresAt, cfg.secret))
+		res["download_url"] = fmt.Sprintf("/api/exports/%s/download?%s", dataExport.ID, query.Encode())
+		res["download_expires_at"] = expiresAt.String()
+	case "failed":
+		res["error"] = dataExport.Error.String
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// downloadDataExport serves a finished archive. It is authenticated by the
+// signed link handed out by getDataExport rather than by a JWT, so that the
+// link can be opened directly in a browser.
+func (cfg *apiConfig) downloadDataExport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve export")
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "invalid download link")
+		return
+	}
+	if err := auth.ValidateResourceSignature(exportResource(id), time.Unix(expires, 0), r.URL.Query().Get("signature"), cfg.secret); err != nil {
+		respondWithError(w, http.StatusForbidden, "invalid download link")
+		return
+	}
+	archive, err := cfg.dbQueries.GetDataExportArchive(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+type generateDataExportJob struct {
+	ExportID uuid.UUID `json:"export_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+// generateDataExport builds the archive of a requested export. A failed
+// attempt marks the export failed; if the job is retried and succeeds the
+// export becomes ready after all.
+func (cfg *apiConfig) generateDataExport(ctx context.Context, job generateDataExportJob) error {
+	archive, err := cfg.buildDataExport(ctx, job.UserID)
+	if err != nil {
+		if err := cfg.dbQueries.FailDataExport(ctx, database.FailDataExportParams{
+			ID:    job.ExportID,
+			Error: sql.NullString{String: "could not generate export", Valid: true},
+		}); err != nil {
+			loggerFrom(ctx).Error("marking data export as failed did not succeed", "error", err)
+		}
+		return fmt.Errorf("could not generate data export: %w", err)
+	}
+	return cfg.dbQueries.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ID:      job.ExportID,
+		Archive: archive,
+	})
+}
+
+func (cfg *apiConfig) buildDataExport(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	chirps, err := cfg.dbQueries.GetChirpsForExport(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get chirps: %w", err)
+	}
+	sessions, err := cfg.dbQueries.GetActiveRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sessions: %w", err)
+	}
+	subscriptions, err := cfg.dbQueries.ListSubscriptionChangesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get subscription history: %w", err)
+	}
+
+	data := export.Data{
+		GeneratedAt: time.Now(),
+		Profile: export.Profile{
+			ID:        user.ID.String(),
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		Chirps:   make([]export.Chirp, 0, len(chirps)),
+		Sessions: make([]export.Session, 0, len(sessions)),
+		ChirpyRed: export.ChirpyRed{
+			Active:  isChirpyRed(user.ChirpyRedExpiresAt),
+			History: make([]export.SubscriptionChange, 0, len(subscriptions)),
+		},
+	}
+	if user.ChirpyRedExpiresAt.Valid {
+		data.ChirpyRed.ExpiresAt = &user.ChirpyRedExpiresAt.Time
+	}
+	for _, chirp := range chirps {
+		data.Chirps = append(data.Chirps, export.Chirp{
+			ID:        chirp.ID.String(),
+			Body:      chirp.Body,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+		})
+	}
+	for _, subscription := range subscriptions {
+		change := export.SubscriptionChange{
+			Event:      subscription.Event,
+			FromStatus: subscription.FromStatus,
+			ToStatus:   subscription.ToStatus,
+			ChangedAt:  subscription.CreatedAt,
+		}
+		if subscription.ExpiresAt.Valid {
+			change.ExpiresAt = &subscription.ExpiresAt.Time
+		}
+		data.ChirpyRed.History = append(data.ChirpyRed.History, change)
+	}
+	for _, session := range sessions {
+		data.Sessions = append(data.Sessions, export.Session{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+	return export.Archive(data)
+}
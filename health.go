@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// expectedSchemaVersion is the newest migration embedded in the binary.
+var expectedSchemaVersion = latestMigration()
+
+const (
+	// readinessTimeout bounds each dependency check.
+	readinessTimeout = 2 * time.Second
+	// jobWorkerStallAfter is how long workers may go without polling the
+	// queue before they are reported as stalled.
+	jobWorkerStallAfter = time.Minute
+)
+
+const (
+	checkOK          = "ok"
+	checkFailed      = "failed"
+	checkDegraded    = "degraded"
+	checkDisabled    = "disabled"
+	checkUnavailable = "unavailable"
+	checkShutdown    = "shutting_down"
+)
+
+type healthCheck struct {
+	Status    string     `json:"status"`
+	LatencyMs float64    `json:"latency_ms,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Version   int64      `json:"version,omitempty"`
+	Expected  int64      `json:"expected,omitempty"`
+	LastPoll  *time.Time `json:"last_poll,omitempty"`
+}
+
+// handleLiveness only reports that the process is up and serving. It never
+// looks at dependencies, so that an outage of Postgres doesn't get healthy
+// instances restarted.
+func handleLiveness(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": checkOK})
+}
+
+// handleReadiness reports whether this instance should receive traffic,
+// with a breakdown per dependency. It fails when the database can't be
+// reached or is missing migrations, and once a shutdown has started. A
+// stalled job worker is reported but doesn't fail readiness, since the API
+// can still serve requests without it.
+func (cfg *apiConfig) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]healthCheck{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range map[string]func(context.Context) healthCheck{
+		"database":   cfg.checkDatabase,
+		"migrations": cfg.checkMigrations,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+			result := check(ctx)
+			mu.Lock()
+			checks[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	checks["jobs"] = cfg.checkJobs()
+
+	status, code := checkOK, http.StatusOK
+	for _, name := range []string{"database", "migrations"} {
+		if checks[name].Status != checkOK {
+			status, code = checkUnavailable, http.StatusServiceUnavailable
+		}
+	}
+	if cfg.shuttingDown.Load() {
+		status, code = checkShutdown, http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, map[string]any{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func (cfg *apiConfig) checkDatabase(ctx context.Context) healthCheck {
+	start := time.Now()
+	err := cfg.db.PingContext(ctx)
+	result := healthCheck{
+		Status:    checkOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status, result.Error = checkFailed, err.Error()
+	}
+	return result
+}
+
+// checkMigrations compares the goose version of the database with the one
+// this build expects. A newer database is fine, since migrations run ahead
+// of a rolling deploy.
+func (cfg *apiConfig) checkMigrations(ctx context.Context) healthCheck {
+	result := healthCheck{Status: checkOK, Expected: expectedSchemaVersion}
+	err := cfg.db.QueryRowContext(ctx,
+		"select coalesce(max(version_id), 0) from goose_db_version where is_applied",
+	).Scan(&result.Version)
+	switch {
+	case err != nil:
+		result.Status, result.Error = checkFailed, err.Error()
+	case result.Version < expectedSchemaVersion:
+		result.Status, result.Error = checkFailed, "database is missing migrations"
+	}
+	return result
+}
+
+func (cfg *apiConfig) checkJobs() healthCheck {
+	if !cfg.jobs.Running() {
+		return healthCheck{Status: checkDisabled}
+	}
+	lastPoll := cfg.jobs.LastPoll()
+	result := healthCheck{Status: checkOK, LastPoll: &lastPoll}
+	if time.Since(lastPoll) > jobWorkerStallAfter {
+		result.Status, result.Error = checkDegraded, "job workers stopped polling"
+	}
+	return result
+}
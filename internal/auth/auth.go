@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -83,4 +86,24 @@ func MakeRefreshToken() (string, error) {
 		return "", fmt.Errorf("could not generate random bytes: %w", err)
 	}
 	return hex.EncodeToString(b[:]), nil
+}
+
+// SignResource returns an HMAC signature granting access to resource until
+// expiresAt. It is meant for links that are handed out without a JWT, such
+// as data export downloads.
+func SignResource(resource string, expiresAt time.Time, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource + "|" + strconv.FormatInt(expiresAt.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func ValidateResourceSignature(resource string, expiresAt time.Time, signature, secret string) error {
+	if time.Now().After(expiresAt) {
+		return fmt.Errorf("signature expired")
+	}
+	expected := SignResource(resource, expiresAt, secret)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
 }
\ No newline at end of file
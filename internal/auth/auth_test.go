@@ -1,6 +1,9 @@
 package auth_test
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,4 +96,146 @@ func TestJWTWithWrongSecret(t *testing.T) {
 	if err == nil {
 		t.Errorf("expected error validating JWT with wrong secret, got none")
 	}
-}
\ No newline at end of file
+}
+
+func TestSignAndValidateResource(t *testing.T) {
+	secret := "supersecretkey"
+	expiresAt := time.Now().Add(time.Minute)
+
+	signature := auth.SignResource("exports/123", expiresAt, secret)
+	if err := auth.ValidateResourceSignature("exports/123", expiresAt, signature, secret); err != nil {
+		t.Errorf("unexpected error validating signature: %v", err)
+	}
+	if err := auth.ValidateResourceSignature("exports/456", expiresAt, signature, secret); err == nil {
+		t.Errorf("expected error validating signature for another resource, got none")
+	}
+	if err := auth.ValidateResourceSignature("exports/123", expiresAt.Add(time.Minute), signature, secret); err == nil {
+		t.Errorf("expected error validating signature with a tampered expiry, got none")
+	}
+}
+
+func TestExpiredResourceSignature(t *testing.T) {
+	secret := "supersecretkey"
+	expiresAt := time.Now().Add(-time.Minute)
+
+	signature := auth.SignResource("exports/123", expiresAt, secret)
+	if err := auth.ValidateResourceSignature("exports/123", expiresAt, signature, secret); err == nil {
+		t.Errorf("expected error validating expired signature, got none")
+	}
+}
+
+func TestMakeAndValidateJWTWithRole(t *testing.T) {
+	userID := uuid.New()
+	secret := "supersecretkey"
+
+	token, err := auth.MakeJWTWithRole(userID, auth.RoleModerator, secret, time.Minute*5)
+	if err != nil {
+		t.Fatalf("unexpected error creating JWT: %v", err)
+	}
+	parsedID, role, err := auth.ValidateJWTWithRole(token, secret)
+	if err != nil {
+		t.Fatalf("unexpected error validating JWT: %v", err)
+	}
+	if parsedID != userID {
+		t.Errorf("expected userID %v, got %v", userID, parsedID)
+	}
+	if role != auth.RoleModerator {
+		t.Errorf("expected role %v, got %v", auth.RoleModerator, role)
+	}
+}
+
+func TestJWTWithoutRoleIsUser(t *testing.T) {
+	secret := "supersecretkey"
+
+	token, err := auth.MakeJWT(uuid.New(), secret, time.Minute*5)
+	if err != nil {
+		t.Fatalf("unexpected error creating JWT: %v", err)
+	}
+	_, role, err := auth.ValidateJWTWithRole(token, secret)
+	if err != nil {
+		t.Fatalf("unexpected error validating JWT: %v", err)
+	}
+	if role != auth.RoleUser {
+		t.Errorf("expected role %v, got %v", auth.RoleUser, role)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	if auth.RoleUser.Can(auth.PermissionModerateContent) {
+		t.Errorf("expected users not to moderate content")
+	}
+	if !auth.RoleModerator.Can(auth.PermissionModerateContent) {
+		t.Errorf("expected moderators to moderate content")
+	}
+	if auth.RoleModerator.Can(auth.PermissionManageUsers) {
+		t.Errorf("expected moderators not to manage users")
+	}
+	if !auth.RoleAdmin.Can(auth.PermissionManageUsers) {
+		t.Errorf("expected admins to manage users")
+	}
+	if auth.Role("owner").Valid() {
+		t.Errorf("expected unknown roles to be invalid")
+	}
+}
+
+func TestRoleOutranks(t *testing.T) {
+	if !auth.RoleModerator.Outranks(auth.RoleUser) || !auth.RoleAdmin.Outranks(auth.RoleModerator) {
+		t.Errorf("expected higher roles to outrank lower ones")
+	}
+	if auth.RoleModerator.Outranks(auth.RoleModerator) || auth.RoleModerator.Outranks(auth.RoleAdmin) {
+		t.Errorf("expected moderators not to outrank their peers or admins")
+	}
+}
+
+func signedWebhookHeaders(body []byte, timestamp time.Time, secrets ...string) http.Header {
+	headers := http.Header{}
+	headers.Set(auth.WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	var signatures []string
+	for _, secret := range secrets {
+		signatures = append(signatures, "v1="+auth.SignWebhook(body, timestamp, secret))
+	}
+	headers.Set(auth.WebhookSignatureHeader, strings.Join(signatures, ","))
+	return headers
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Now()
+	headers := signedWebhookHeaders(body, now, "current")
+
+	if err := auth.VerifyWebhookSignature(headers, body, []string{"current"}, 5*time.Minute, now); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+	if err := auth.VerifyWebhookSignature(headers, []byte(`{"event":"user.downgraded"}`), []string{"current"}, 5*time.Minute, now); err == nil {
+		t.Errorf("expected error for tampered body, got none")
+	}
+	if err := auth.VerifyWebhookSignature(headers, body, []string{"other"}, 5*time.Minute, now); err == nil {
+		t.Errorf("expected error for wrong secret, got none")
+	}
+}
+
+func TestWebhookSignatureRotation(t *testing.T) {
+	body := []byte(`{}`)
+	now := time.Now()
+
+	if err := auth.VerifyWebhookSignature(signedWebhookHeaders(body, now, "old"), body, []string{"new", "old"}, time.Minute, now); err != nil {
+		t.Errorf("expected signature with an older active secret to be valid, got %v", err)
+	}
+	if err := auth.VerifyWebhookSignature(signedWebhookHeaders(body, now, "retired", "new"), body, []string{"new"}, time.Minute, now); err != nil {
+		t.Errorf("expected any matching signature to be valid, got %v", err)
+	}
+}
+
+func TestWebhookReplayOutsideTolerance(t *testing.T) {
+	body := []byte(`{}`)
+	sentAt := time.Now().Add(-10 * time.Minute)
+	headers := signedWebhookHeaders(body, sentAt, "secret")
+
+	if err := auth.VerifyWebhookSignature(headers, body, []string{"secret"}, 5*time.Minute, time.Now()); err == nil {
+		t.Errorf("expected error for replayed webhook, got none")
+	}
+	headers.Set(auth.WebhookTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	if err := auth.VerifyWebhookSignature(headers, body, []string{"secret"}, 5*time.Minute, time.Now()); err == nil {
+		t.Errorf("expected error for webhook with a rewritten timestamp, got none")
+	}
+}
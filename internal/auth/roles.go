@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionModerateContent  Permission = "moderate_content"
+	PermissionManageModeration Permission = "manage_moderation"
+	PermissionManageUsers      Permission = "manage_users"
+	PermissionViewMetrics      Permission = "view_metrics"
+	PermissionResetData        Permission = "reset_data"
+	PermissionManageWebhooks   Permission = "manage_webhooks"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermissionModerateContent,
+		PermissionViewMetrics,
+	},
+	RoleAdmin: {
+		PermissionModerateContent,
+		PermissionManageModeration,
+		PermissionManageUsers,
+		PermissionViewMetrics,
+		PermissionResetData,
+		PermissionManageWebhooks,
+	},
+}
+
+// roleRanks orders roles for moderation: nobody may act against a user of
+// the same or a higher rank.
+var roleRanks = map[Role]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// Outranks reports whether r may moderate users with role other.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the JWT claims issued by chirpy. Tokens issued before roles
+// existed carry no role and are treated as RoleUser.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role Role `json:"role,omitempty"`
+}
+
+func MakeJWTWithRole(userID uuid.UUID, role Role, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Role: role,
+	})
+	return token.SignedString([]byte(tokenSecret))
+}
+
+func ValidateJWTWithRole(tokenString, tokenSecret string) (uuid.UUID, Role, error) {
+	var c Claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	})
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	id, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	if c.Role == "" {
+		c.Role = RoleUser
+	}
+	if !c.Role.Valid() {
+		return uuid.UUID{}, "", fmt.Errorf("unknown role %q", c.Role)
+	}
+	return id, c.Role, nil
+}
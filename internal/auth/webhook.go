@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookTimestampHeader carries the Unix time a webhook was sent at.
+	WebhookTimestampHeader = "Polka-Timestamp"
+	// WebhookSignatureHeader carries one or more comma-separated "v1=<hex>"
+	// signatures, one per secret the sender is signing with.
+	WebhookSignatureHeader = "Polka-Signature"
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<unix timestamp>.<body>".
+// Signing the timestamp ties the signature to the moment the webhook was
+// sent, so a captured request can only be replayed within the tolerance
+// window.
+func SignWebhook(body []byte, timestamp time.Time, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature headers of a webhook against
+// body. Any signature matching any of secrets is accepted, so that secrets
+// can be rotated without downtime. The timestamp must be within tolerance of
+// now in either direction.
+func VerifyWebhookSignature(headers http.Header, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	rawTimestamp := headers.Get(WebhookTimestampHeader)
+	if rawTimestamp == "" {
+		return fmt.Errorf("missing %s header", WebhookTimestampHeader)
+	}
+	unix, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header", WebhookTimestampHeader)
+	}
+	timestamp := time.Unix(unix, 0)
+	if timestamp.Before(now.Add(-tolerance)) || timestamp.After(now.Add(tolerance)) {
+		return fmt.Errorf("timestamp outside of tolerance window")
+	}
+
+	var signatures []string
+	for _, part := range strings.Split(headers.Get(WebhookSignatureHeader), ",") {
+		if signature, ok := strings.CutPrefix(strings.TrimSpace(part), "v1="); ok {
+			signatures = append(signatures, signature)
+		}
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("missing %s header", WebhookSignatureHeader)
+	}
+	for _, secret := range secrets {
+		expected := []byte(SignWebhook(body, timestamp, secret))
+		for _, signature := range signatures {
+			if hmac.Equal(expected, []byte(signature)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid signature")
+}
@@ -0,0 +1,127 @@
+// Package billing decides how Polka subscription events change a user's
+// Chirpy Red subscription. It holds no I/O so that the rules can be tested
+// on their own.
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusNone     Status = "none"
+	StatusActive   Status = "active"
+	StatusPastDue  Status = "past_due"
+	StatusCanceled Status = "canceled"
+	StatusRefunded Status = "refunded"
+	StatusExpired  Status = "expired"
+)
+
+type Event string
+
+const (
+	EventUpgraded      Event = "user.upgraded"
+	EventDowngraded    Event = "user.downgraded"
+	EventRenewed       Event = "subscription.renewed"
+	EventPaymentFailed Event = "payment.failed"
+	EventRefundIssued  Event = "refund.issued"
+	// EventExpired is not sent by Polka. It is recorded by chirpy once an
+	// active or past due subscription runs out.
+	EventExpired Event = "subscription.expired"
+)
+
+// Valid reports whether e is a Polka event.
+func (e Event) Valid() bool {
+	switch e {
+	case EventUpgraded, EventDowngraded, EventRenewed, EventPaymentFailed, EventRefundIssued:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidTransition is returned for events that don't apply to the
+// subscription's current status, such as renewing a refunded subscription.
+var ErrInvalidTransition = errors.New("invalid subscription transition")
+
+type Policy struct {
+	// Period is how long an upgrade or renewal pays for.
+	Period time.Duration
+	// GracePeriod is how long Chirpy Red stays active after a failed
+	// payment, to give the user time to fix their payment method.
+	GracePeriod time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Period:      30 * 24 * time.Hour,
+		GracePeriod: 7 * 24 * time.Hour,
+	}
+}
+
+// State is a user's subscription. ExpiresAt is zero when the user never
+// had Chirpy Red.
+type State struct {
+	Status    Status
+	ExpiresAt time.Time
+}
+
+// Active reports whether the user has Chirpy Red at now.
+func (s State) Active(now time.Time) bool {
+	return (s.Status == StatusActive || s.Status == StatusPastDue) && now.Before(s.ExpiresAt)
+}
+
+// Apply returns the state after event is received at now.
+func (p Policy) Apply(state State, event Event, now time.Time) (State, error) {
+	switch event {
+	case EventUpgraded:
+		return State{Status: StatusActive, ExpiresAt: p.extend(state, now)}, nil
+	case EventRenewed:
+		// Expired is accepted too: Polka's renewal may arrive after
+		// expireChirpyRed already recorded the expiry, and it is still paid
+		// for.
+		if state.Status != StatusActive && state.Status != StatusPastDue && state.Status != StatusExpired {
+			break
+		}
+		return State{Status: StatusActive, ExpiresAt: p.extend(state, now)}, nil
+	case EventPaymentFailed:
+		if state.Status != StatusActive && state.Status != StatusExpired {
+			break
+		}
+		expiresAt := now.Add(p.GracePeriod)
+		if state.ExpiresAt.After(expiresAt) {
+			expiresAt = state.ExpiresAt
+		}
+		return State{Status: StatusPastDue, ExpiresAt: expiresAt}, nil
+	case EventDowngraded:
+		if state.Status != StatusActive && state.Status != StatusPastDue {
+			break
+		}
+		return State{Status: StatusCanceled, ExpiresAt: now}, nil
+	case EventRefundIssued:
+		if state.Status == StatusNone || state.Status == StatusRefunded {
+			break
+		}
+		return State{Status: StatusRefunded, ExpiresAt: now}, nil
+	case EventExpired:
+		if (state.Status != StatusActive && state.Status != StatusPastDue) || now.Before(state.ExpiresAt) {
+			break
+		}
+		return State{Status: StatusExpired, ExpiresAt: state.ExpiresAt}, nil
+	default:
+		return state, fmt.Errorf("unknown subscription event %q", event)
+	}
+	return state, fmt.Errorf("%w: %s while %s", ErrInvalidTransition, event, state.Status)
+}
+
+// extend adds a period to the current expiry, or to now if the
+// subscription already lapsed, so that paying early never loses days.
+func (p Policy) extend(state State, now time.Time) time.Time {
+	from := now
+	if state.Active(now) {
+		from = state.ExpiresAt
+	}
+	return from.Add(p.Period)
+}
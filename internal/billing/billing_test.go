@@ -0,0 +1,140 @@
+package billing_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/billing"
+)
+
+var now = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+const day = 24 * time.Hour
+
+func TestUpgradeFromNothing(t *testing.T) {
+	state, err := billing.DefaultPolicy().Apply(billing.State{Status: billing.StatusNone}, billing.EventUpgraded, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Status != billing.StatusActive || !state.ExpiresAt.Equal(now.Add(30*day)) {
+		t.Errorf("expected active for 30 days, got %+v", state)
+	}
+}
+
+func TestRenewalExtendsFromCurrentExpiry(t *testing.T) {
+	current := billing.State{Status: billing.StatusActive, ExpiresAt: now.Add(5 * day)}
+	state, err := billing.DefaultPolicy().Apply(current, billing.EventRenewed, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !state.ExpiresAt.Equal(now.Add(35 * day)) {
+		t.Errorf("expected renewal to extend from current expiry, got %v", state.ExpiresAt)
+	}
+}
+
+func TestRenewalAfterLapseExtendsFromNow(t *testing.T) {
+	current := billing.State{Status: billing.StatusPastDue, ExpiresAt: now.Add(-day)}
+	state, err := billing.DefaultPolicy().Apply(current, billing.EventRenewed, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Status != billing.StatusActive || !state.ExpiresAt.Equal(now.Add(30*day)) {
+		t.Errorf("expected active for 30 days from now, got %+v", state)
+	}
+}
+
+func TestPaymentFailedGracePeriod(t *testing.T) {
+	policy := billing.DefaultPolicy()
+	current := billing.State{Status: billing.StatusActive, ExpiresAt: now}
+	state, err := policy.Apply(current, billing.EventPaymentFailed, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Status != billing.StatusPastDue || !state.ExpiresAt.Equal(now.Add(policy.GracePeriod)) {
+		t.Errorf("expected past due until the end of the grace period, got %+v", state)
+	}
+	if !state.Active(now.Add(time.Hour)) {
+		t.Errorf("expected Chirpy Red to stay active during the grace period")
+	}
+	if state.Active(now.Add(policy.GracePeriod)) {
+		t.Errorf("expected Chirpy Red to end with the grace period")
+	}
+
+	paidUp := billing.State{Status: billing.StatusActive, ExpiresAt: now.Add(20 * day)}
+	state, _ = policy.Apply(paidUp, billing.EventPaymentFailed, now)
+	if !state.ExpiresAt.Equal(paidUp.ExpiresAt) {
+		t.Errorf("expected a failed payment not to shorten a paid period, got %v", state.ExpiresAt)
+	}
+}
+
+func TestDowngradeAndRefundEndImmediately(t *testing.T) {
+	current := billing.State{Status: billing.StatusActive, ExpiresAt: now.Add(10 * day)}
+	for _, event := range []billing.Event{billing.EventDowngraded, billing.EventRefundIssued} {
+		state, err := billing.DefaultPolicy().Apply(current, event, now)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", event, err)
+		}
+		if state.Active(now) {
+			t.Errorf("expected %s to end Chirpy Red, got %+v", event, state)
+		}
+	}
+}
+
+func TestInvalidTransitions(t *testing.T) {
+	cases := []struct {
+		status billing.Status
+		event  billing.Event
+	}{
+		{billing.StatusNone, billing.EventRenewed},
+		{billing.StatusCanceled, billing.EventRenewed},
+		{billing.StatusRefunded, billing.EventRefundIssued},
+		{billing.StatusNone, billing.EventDowngraded},
+		{billing.StatusPastDue, billing.EventPaymentFailed},
+	}
+	for _, c := range cases {
+		current := billing.State{Status: c.status}
+		state, err := billing.DefaultPolicy().Apply(current, c.event, now)
+		if !errors.Is(err, billing.ErrInvalidTransition) {
+			t.Errorf("expected invalid transition for %s while %s, got %v", c.event, c.status, err)
+		}
+		if state != current {
+			t.Errorf("expected state to be unchanged, got %+v", state)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	policy := billing.DefaultPolicy()
+	lapsed := billing.State{Status: billing.StatusPastDue, ExpiresAt: now.Add(-time.Minute)}
+	state, err := policy.Apply(lapsed, billing.EventExpired, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Status != billing.StatusExpired || !state.ExpiresAt.Equal(lapsed.ExpiresAt) {
+		t.Errorf("expected expired with the original expiry, got %+v", state)
+	}
+
+	current := billing.State{Status: billing.StatusActive, ExpiresAt: now.Add(day)}
+	if _, err := policy.Apply(current, billing.EventExpired, now); !errors.Is(err, billing.ErrInvalidTransition) {
+		t.Errorf("expected a subscription that hasn't run out not to expire, got %v", err)
+	}
+
+	// Polka's renewal or failed payment may arrive after the expiry was
+	// recorded.
+	later := now.Add(time.Hour)
+	renewed, err := policy.Apply(state, billing.EventRenewed, later)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if renewed.Status != billing.StatusActive || !renewed.ExpiresAt.Equal(later.Add(policy.Period)) {
+		t.Errorf("expected a late renewal to extend from now, got %+v", renewed)
+	}
+	pastDue, err := policy.Apply(state, billing.EventPaymentFailed, later)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pastDue.Status != billing.StatusPastDue || !pastDue.ExpiresAt.Equal(later.Add(policy.GracePeriod)) {
+		t.Errorf("expected a late failed payment to start the grace period, got %+v", pastDue)
+	}
+}
@@ -0,0 +1,463 @@
+// Package config loads and validates chirpy's settings.
+//
+// Every setting can come from four places. Later ones override earlier
+// ones:
+//
+//  1. the defaults in Default
+//  2. a YAML file, named by the --config flag or CHIRPY_CONFIG
+//  3. environment variables, such as DB_URL
+//  4. command-line flags, such as --database-url
+//
+// Flag names are derived from the YAML keys: server.read_timeout is set by
+// --server-read-timeout. Run chirpy with -h for the full list.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/spam"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the server. Fields are tagged with their
+// environment variable; secret fields are left out of Redacted.
+type Config struct {
+	// Platform is "dev" on developer machines, which enables the reset
+	// endpoint, plain HTTP webhooks and short secrets.
+	Platform    string `yaml:"platform" env:"PLATAFORM" usage:"deployment platform; dev enables development-only features"`
+	DatabaseURL string `yaml:"database_url" env:"DB_URL" secret:"url" usage:"Postgres connection URL"`
+	Secret      string `yaml:"secret" env:"SECRET" secret:"true" usage:"key that signs access tokens"`
+	LogLevel    string `yaml:"log_level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+
+	MetricsToken  string `yaml:"metrics_token" env:"METRICS_TOKEN" secret:"true" usage:"bearer token for scraping /metrics; without it, scraping outside dev needs a view_metrics user's token"`
+	TraceExporter string `yaml:"trace_exporter" env:"OTEL_TRACES_EXPORTER" usage:"otlp, stdout or none"`
+
+	RateLimitStore    string `yaml:"rate_limit_store" env:"RATE_LIMIT_STORE" usage:"memory or postgres"`
+	TrustProxyHeaders bool   `yaml:"trust_proxy_headers" env:"TRUST_PROXY_HEADERS" usage:"use X-Forwarded-For to identify clients"`
+
+	AutoMigrate bool `yaml:"auto_migrate" env:"AUTO_MIGRATE" usage:"apply pending migrations at startup"`
+
+	ModerationWordsFile string `yaml:"moderation_words_file" env:"MODERATION_WORDS_FILE" usage:"file of moderation words to load at startup"`
+	EntitlementsFile    string `yaml:"entitlements_file" env:"ENTITLEMENTS_FILE" usage:"JSON file overriding the perks of each tier"`
+
+	AccountDeletionGracePeriod time.Duration `yaml:"account_deletion_grace_period" env:"ACCOUNT_DELETION_GRACE_PERIOD" usage:"how long deleted accounts can be restored"`
+	ChirpyRedGracePeriod       time.Duration `yaml:"chirpy_red_grace_period" env:"CHIRPY_RED_GRACE_PERIOD" usage:"how long Chirpy Red survives a failed payment"`
+
+	Tokens Tokens `yaml:"tokens"`
+	Polka  Polka  `yaml:"polka"`
+	Spam   Spam   `yaml:"spam"`
+	Server Server `yaml:"server"`
+}
+
+type Tokens struct {
+	AccessTTL  time.Duration `yaml:"access_ttl" env:"ACCESS_TOKEN_TTL" usage:"lifetime of access tokens"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"REFRESH_TOKEN_TTL" usage:"lifetime of refresh tokens"`
+}
+
+type Polka struct {
+	APIKey           string        `yaml:"api_key" env:"POLKA_KEY" secret:"true" usage:"legacy API key accepted from Polka"`
+	WebhookSecrets   []string      `yaml:"webhook_secrets" env:"POLKA_WEBHOOK_SECRETS" secret:"true" usage:"comma-separated secrets that sign Polka webhooks"`
+	WebhookTolerance time.Duration `yaml:"webhook_tolerance" env:"POLKA_WEBHOOK_TOLERANCE" usage:"how old a signed webhook may be"`
+	AllowAPIKey      bool          `yaml:"allow_api_key" env:"POLKA_ALLOW_API_KEY" usage:"accept unsigned webhooks carrying the API key"`
+}
+
+type Spam struct {
+	Action             string        `yaml:"action" env:"SPAM_ACTION" usage:"reject, review or throttle"`
+	DuplicateWindow    time.Duration `yaml:"duplicate_window" env:"SPAM_DUPLICATE_WINDOW" usage:"how far back duplicate chirps are looked for"`
+	MaxDuplicates      int           `yaml:"max_duplicates" env:"SPAM_MAX_DUPLICATES" usage:"duplicates within the window that make a chirp spam"`
+	MaxURLs            int           `yaml:"max_urls" env:"SPAM_MAX_URLS" usage:"links allowed in a chirp"`
+	BlockedDomainsFile string        `yaml:"blocked_domains_file" env:"SPAM_BLOCKED_DOMAINS_FILE" usage:"file of domains chirps may not link to"`
+}
+
+type Server struct {
+	Addr              string        `yaml:"addr" env:"ADDR" usage:"address the API listens on"`
+	TLSCertFile       string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE" usage:"TLS certificate; serves HTTPS when set"`
+	TLSKeyFile        string        `yaml:"tls_key_file" env:"TLS_KEY_FILE" usage:"TLS private key"`
+	RedirectAddr      string        `yaml:"redirect_addr" env:"HTTP_REDIRECT_ADDR" usage:"address that redirects plain HTTP to HTTPS"`
+	HTTP2             bool          `yaml:"http2" env:"HTTP2" usage:"offer HTTP/2 over TLS"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" usage:"time allowed to read a whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" usage:"time allowed to write a response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" usage:"how long idle keep-alive connections stay open"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" usage:"largest request headers accepted"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long shutdown waits for requests and jobs"`
+	DrainDelay        time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" usage:"how long readiness fails before the server stops accepting requests"`
+}
+
+// TLS reports whether the server serves HTTPS.
+func (s Server) TLS() bool {
+	return s.TLSCertFile != ""
+}
+
+func Default() Config {
+	spamDefaults := spam.DefaultConfig()
+	return Config{
+		LogLevel:                   "info",
+		RateLimitStore:             "memory",
+		AccountDeletionGracePeriod: 30 * 24 * time.Hour,
+		ChirpyRedGracePeriod:       billing.DefaultPolicy().GracePeriod,
+		Tokens: Tokens{
+			AccessTTL:  time.Hour,
+			RefreshTTL: 60 * 24 * time.Hour,
+		},
+		Polka: Polka{
+			WebhookTolerance: 5 * time.Minute,
+		},
+		Spam: Spam{
+			Action:          string(spamDefaults.Action),
+			DuplicateWindow: spamDefaults.DuplicateWindow,
+			MaxDuplicates:   spamDefaults.MaxDuplicates,
+			MaxURLs:         spamDefaults.MaxURLs,
+		},
+		Server: Server{
+			Addr:              ":8080",
+			HTTP2:             true,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			ShutdownTimeout:   30 * time.Second,
+			DrainDelay:        5 * time.Second,
+		},
+	}
+}
+
+// ConfigFileEnv names the YAML file to load when --config isn't given.
+const ConfigFileEnv = "CHIRPY_CONFIG"
+
+// Load resolves the configuration from args, which don't include the
+// program name, and the environment seen through lookupEnv. It returns the
+// arguments left after the flags. A -h flag returns flag.ErrHelp after
+// printing the usage to output.
+//
+// Load doesn't validate the result, since commands like migrate only need a
+// few of the settings; call Validate before serving.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Config, []string, error) {
+	cfg := Default()
+	fields := settings(&cfg)
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(output)
+	file := fs.String("config", "", "YAML config file (env "+ConfigFileEnv+")")
+	flags := map[string]string{}
+	for _, f := range fields {
+		fs.Var(&flagValue{field: f, set: flags}, f.flag, fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *file == "" {
+		*file, _ = lookupEnv(ConfigFileEnv)
+	}
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return cfg, nil, err
+		}
+	}
+	for _, f := range fields {
+		if value, ok := lookupEnv(f.env); ok && value != "" {
+			if err := f.set(value); err != nil {
+				return cfg, nil, fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+		}
+	}
+	for _, f := range fields {
+		if value, ok := flags[f.flag]; ok {
+			if err := f.set(value); err != nil {
+				return cfg, nil, fmt.Errorf("invalid --%s: %w", f.flag, err)
+			}
+		}
+	}
+	return cfg, fs.Args(), nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open config file: %w", err)
+	}
+	defer f.Close()
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// minSecretLength is the shortest SECRET accepted outside of dev; HS256
+// keys should be at least as long as the hash.
+const minSecretLength = 32
+
+// Validate reports every invalid or missing setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DatabaseURL != "", "DB_URL is required")
+	if c.DatabaseURL != "" {
+		check(validDatabaseURL(c.DatabaseURL), "DB_URL must be a postgres:// URL or a key=value connection string")
+	}
+	check(c.Secret != "", "SECRET is required")
+	if c.Platform != "dev" && c.Secret != "" {
+		check(strongSecret(c.Secret), "SECRET must be at least %d characters and not repetitive", minSecretLength)
+	}
+	if c.Platform != "dev" && c.MetricsToken != "" {
+		check(len(c.MetricsToken) >= 16, "METRICS_TOKEN must be at least 16 characters")
+	}
+	check(c.Polka.APIKey != "" || !c.Polka.AllowAPIKey, "POLKA_ALLOW_API_KEY requires POLKA_KEY")
+
+	check(c.Tokens.AccessTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+	check(c.Tokens.RefreshTTL > c.Tokens.AccessTTL, "REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+
+	check(c.RateLimitStore == "memory" || c.RateLimitStore == "postgres", "RATE_LIMIT_STORE must be memory or postgres")
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL must be debug, info, warn or error")
+	}
+	switch strings.ToLower(c.TraceExporter) {
+	case "", "none", "otlp", "stdout", "console":
+	default:
+		check(false, "OTEL_TRACES_EXPORTER must be otlp, stdout or none")
+	}
+
+	for env, d := range map[string]time.Duration{
+		"ACCOUNT_DELETION_GRACE_PERIOD": c.AccountDeletionGracePeriod,
+		"CHIRPY_RED_GRACE_PERIOD":       c.ChirpyRedGracePeriod,
+		"POLKA_WEBHOOK_TOLERANCE":       c.Polka.WebhookTolerance,
+		"SHUTDOWN_TIMEOUT":              c.Server.ShutdownTimeout,
+		"SHUTDOWN_DRAIN_DELAY":          c.Server.DrainDelay,
+	} {
+		check(d >= 0, "%s can't be negative", env)
+	}
+	check(c.Server.Addr != "", "ADDR is required")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(c.Server.RedirectAddr == "" || c.Server.TLS(), "HTTP_REDIRECT_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE")
+	check(c.Server.MaxHeaderBytes > 0, "SERVER_MAX_HEADER_BYTES must be positive")
+	return errors.Join(errs...)
+}
+
+// strongSecret rejects short secrets and long ones made of a handful of
+// characters, like "aaaa…".
+func strongSecret(secret string) bool {
+	if len(secret) < minSecretLength {
+		return false
+	}
+	distinct := map[rune]bool{}
+	for _, r := range secret {
+		distinct[r] = true
+	}
+	return len(distinct) >= 8
+}
+
+const redacted = "[redacted]"
+
+// Redacted returns a copy of c that is safe to print: secrets are replaced
+// and only the password is removed from the database URL.
+func (c Config) Redacted() Config {
+	for _, f := range settings(&c) {
+		switch f.secret {
+		case "true":
+			switch v := f.value.Interface().(type) {
+			case string:
+				if v != "" {
+					f.value.SetString(redacted)
+				}
+			case []string:
+				masked := make([]string, len(v))
+				for i := range masked {
+					masked[i] = redacted
+				}
+				f.value.Set(reflect.ValueOf(masked))
+			}
+		case "url":
+			if v := f.value.String(); v != "" {
+				f.value.SetString(redactDatabaseURL(v))
+			}
+		}
+	}
+	return c
+}
+
+// dsnPair matches one key=value pair of a lib/pq connection string, where
+// values may be single-quoted with backslash escapes.
+var dsnPair = regexp.MustCompile(`(\w+)\s*=\s*('(?:[^'\\]|\\.)*'|[^\s']+)`)
+
+// validDatabaseURL accepts the two forms lib/pq does: a postgres:// URL and
+// a key=value connection string.
+func validDatabaseURL(raw string) bool {
+	if strings.HasPrefix(raw, "postgres://") || strings.HasPrefix(raw, "postgresql://") {
+		_, err := url.Parse(raw)
+		return err == nil
+	}
+	rest := strings.TrimSpace(dsnPair.ReplaceAllString(raw, ""))
+	return rest == "" && dsnPair.MatchString(raw)
+}
+
+// isPasswordKey reports whether a connection parameter holds a secret.
+func isPasswordKey(key string) bool {
+	key = strings.ToLower(key)
+	return key == "password" || key == "sslpassword"
+}
+
+// redactDatabaseURL masks the password of a database URL wherever lib/pq
+// accepts one: in the userinfo or a query parameter of a URL, or as a pair
+// of a key=value connection string. Anything else is redacted whole, since
+// there is no telling where its password is.
+func redactDatabaseURL(raw string) string {
+	if strings.HasPrefix(raw, "postgres://") || strings.HasPrefix(raw, "postgresql://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return redacted
+		}
+		query := u.Query()
+		for key := range query {
+			if isPasswordKey(key) {
+				query.Set(key, "xxxxx")
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.Redacted()
+	}
+	if !validDatabaseURL(raw) {
+		return redacted
+	}
+	return dsnPair.ReplaceAllStringFunc(raw, func(pair string) string {
+		key := dsnPair.FindStringSubmatch(pair)[1]
+		if isPasswordKey(key) {
+			return key + "=xxxxx"
+		}
+		return pair
+	})
+}
+
+// Print writes the redacted configuration as YAML, in the format Load
+// reads.
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// setting is one leaf field of Config.
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	secret string
+	value  reflect.Value
+}
+
+// settings lists the leaf fields of cfg, addressable so they can be set.
+func settings(cfg *Config) []setting {
+	var out []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name := prefix + strings.ReplaceAll(field.Tag.Get("yaml"), "_", "-")
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), name+"-")
+				continue
+			}
+			out = append(out, setting{
+				env:    field.Tag.Get("env"),
+				flag:   name,
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// set parses raw into the field.
+func (s setting) set(raw string) error {
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(raw)
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// flagValue records flags as they are parsed, so that they can be applied
+// after the config file and the environment.
+type flagValue struct {
+	field setting
+	set   map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f == nil || !f.field.value.IsValid() {
+		return ""
+	}
+	if f.field.secret != "" {
+		return ""
+	}
+	return fmt.Sprint(f.field.value.Interface())
+}
+
+func (f *flagValue) Set(raw string) error {
+	probe := reflect.New(f.field.value.Type()).Elem()
+	if err := (setting{value: probe}).set(raw); err != nil {
+		return err
+	}
+	f.set[f.field.flag] = raw
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.field.value.Kind() == reflect.Bool
+}
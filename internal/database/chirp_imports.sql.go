@@ -0,0 +1,77 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chirp_imports.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createChirpImport = `-- name: CreateChirpImport :exec
+INSERT INTO chirp_imports (user_id, source_id, chirp_id, imported_at)
+VALUES ($1, $2, $3, NOW())
+`
+
+type CreateChirpImportParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	SourceID string    `json:"source_id"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) CreateChirpImport(ctx context.Context, arg CreateChirpImportParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpImport, arg.UserID, arg.SourceID, arg.ChirpID)
+	return err
+}
+
+const createImportedChirp = `-- name: CreateImportedChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(), $1, $1, $2, $3
+)
+RETURNING id, created_at, updated_at, body, user_id
+`
+
+type CreateImportedChirpParams struct {
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) CreateImportedChirp(ctx context.Context, arg CreateImportedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createImportedChirp, arg.CreatedAt, arg.Body, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getChirpImport = `-- name: GetChirpImport :one
+select user_id, source_id, chirp_id, imported_at from chirp_imports where user_id = $1 and source_id = $2
+`
+
+type GetChirpImportParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	SourceID string    `json:"source_id"`
+}
+
+func (q *Queries) GetChirpImport(ctx context.Context, arg GetChirpImportParams) (ChirpImport, error) {
+	row := q.db.QueryRowContext(ctx, getChirpImport, arg.UserID, arg.SourceID)
+	var i ChirpImport
+	err := row.Scan(
+		&i.UserID,
+		&i.SourceID,
+		&i.ChirpID,
+		&i.ImportedAt,
+	)
+	return i, err
+}
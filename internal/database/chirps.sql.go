@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -55,12 +57,56 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirpByIDReturning = `-- name: DeleteChirpByIDReturning :one
+delete from chirps where id = $1
+RETURNING id, created_at, updated_at, body, user_id
+`
+
+// Deletes a chirp whoever can see it, for operators.
+func (q *Queries) DeleteChirpByIDReturning(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, deleteChirpByIDReturning, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
 const getAllChirps = `-- name: GetAllChirps :many
-select id, created_at, updated_at, body, user_id from chirps order by created_at
+select chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id from chirps
+join users on users.id = chirps.user_id
+where users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = $1::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = $1::uuid)
+order by
+  case when $2::boolean then chirps.created_at end desc,
+  chirps.created_at, chirps.id
+limit $4::int offset $3::int
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetAllChirpsParams struct {
+	ViewerID   uuid.NullUUID `json:"viewer_id"`
+	Newest     bool          `json:"newest"`
+	PageOffset int32         `json:"page_offset"`
+	PageLimit  sql.NullInt32 `json:"page_limit"`
+}
+
+// A null page_limit returns every chirp from page_offset on. id breaks ties
+// so that pages don't overlap.
+func (q *Queries) GetAllChirps(ctx context.Context, arg GetAllChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps,
+		arg.ViewerID,
+		arg.Newest,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -89,11 +135,36 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAllChirpsFromAuthorID = `-- name: GetAllChirpsFromAuthorID :many
-select id, created_at, updated_at, body, user_id from chirps where user_id = $1 order by created_at
+select chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id from chirps
+join users on users.id = chirps.user_id
+where chirps.user_id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = $2::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = $2::uuid)
+order by
+  case when $3::boolean then chirps.created_at end desc,
+  chirps.created_at, chirps.id
+limit $5::int offset $4::int
 `
 
-func (q *Queries) GetAllChirpsFromAuthorID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsFromAuthorID, userID)
+type GetAllChirpsFromAuthorIDParams struct {
+	UserID     uuid.UUID     `json:"user_id"`
+	ViewerID   uuid.NullUUID `json:"viewer_id"`
+	Newest     bool          `json:"newest"`
+	PageOffset int32         `json:"page_offset"`
+	PageLimit  sql.NullInt32 `json:"page_limit"`
+}
+
+func (q *Queries) GetAllChirpsFromAuthorID(ctx context.Context, arg GetAllChirpsFromAuthorIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirpsFromAuthorID,
+		arg.UserID,
+		arg.ViewerID,
+		arg.Newest,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -122,11 +193,120 @@ func (q *Queries) GetAllChirpsFromAuthorID(ctx context.Context, userID uuid.UUID
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-select id, created_at, updated_at, body, user_id from chirps where id = $1
+select chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id from chirps
+join users on users.id = chirps.user_id
+where chirps.id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = $2::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = $2::uuid)
+`
+
+type GetChirpByIDParams struct {
+	ID       uuid.UUID     `json:"id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpByID(ctx context.Context, arg GetChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getChirpsForExport = `-- name: GetChirpsForExport :many
+select id, created_at, updated_at, body, user_id from chirps where user_id = $1 order by created_at
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+func (q *Queries) GetChirpsForExport(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsForExport, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentChirpBodiesByUser = `-- name: GetRecentChirpBodiesByUser :many
+select body from chirps
+where user_id = $1 and created_at > $2::timestamp
+  and ($3::uuid is null or id <> $3::uuid)
+order by created_at desc
+limit 100
+`
+
+type GetRecentChirpBodiesByUserParams struct {
+	UserID     uuid.UUID     `json:"user_id"`
+	Since      time.Time     `json:"since"`
+	ExcludedID uuid.NullUUID `json:"excluded_id"`
+}
+
+// excluded_id leaves out the chirp being edited, so that it isn't counted
+// as a duplicate of itself.
+func (q *Queries) GetRecentChirpBodiesByUser(ctx context.Context, arg GetRecentChirpBodiesByUserParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpBodiesByUser, arg.UserID, arg.Since, arg.ExcludedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		items = append(items, body)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+update chirps
+set body = $2, updated_at = NOW()
+where id = $1
+RETURNING id, created_at, updated_at, body, user_id
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
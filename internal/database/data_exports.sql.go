@@ -0,0 +1,121 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: data_exports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const completeDataExport = `-- name: CompleteDataExport :exec
+update data_exports
+set status = 'ready', archive = $2, completed_at = NOW(), updated_at = NOW()
+where id = $1
+`
+
+type CompleteDataExportParams struct {
+	ID      uuid.UUID `json:"id"`
+	Archive []byte    `json:"archive"`
+}
+
+func (q *Queries) CompleteDataExport(ctx context.Context, arg CompleteDataExportParams) error {
+	_, err := q.db.ExecContext(ctx, completeDataExport, arg.ID, arg.Archive)
+	return err
+}
+
+const createDataExport = `-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, 'pending'
+)
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at
+`
+
+type CreateDataExportRow struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Status      string         `json:"status"`
+	Error       sql.NullString `json:"error"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+}
+
+func (q *Queries) CreateDataExport(ctx context.Context, userID uuid.UUID) (CreateDataExportRow, error) {
+	row := q.db.QueryRowContext(ctx, createDataExport, userID)
+	var i CreateDataExportRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Status,
+		&i.Error,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const failDataExport = `-- name: FailDataExport :exec
+update data_exports
+set status = 'failed', error = $2, completed_at = NOW(), updated_at = NOW()
+where id = $1
+`
+
+type FailDataExportParams struct {
+	ID    uuid.UUID      `json:"id"`
+	Error sql.NullString `json:"error"`
+}
+
+func (q *Queries) FailDataExport(ctx context.Context, arg FailDataExportParams) error {
+	_, err := q.db.ExecContext(ctx, failDataExport, arg.ID, arg.Error)
+	return err
+}
+
+const getDataExport = `-- name: GetDataExport :one
+select id, created_at, updated_at, user_id, status, error, completed_at
+from data_exports
+where id = $1
+`
+
+type GetDataExportRow struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Status      string         `json:"status"`
+	Error       sql.NullString `json:"error"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+}
+
+func (q *Queries) GetDataExport(ctx context.Context, id uuid.UUID) (GetDataExportRow, error) {
+	row := q.db.QueryRowContext(ctx, getDataExport, id)
+	var i GetDataExportRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Status,
+		&i.Error,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getDataExportArchive = `-- name: GetDataExportArchive :one
+select archive from data_exports where id = $1 and status = 'ready'
+`
+
+func (q *Queries) GetDataExportArchive(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	row := q.db.QueryRowContext(ctx, getDataExportArchive, id)
+	var archive []byte
+	err := row.Scan(&archive)
+	return archive, err
+}
@@ -0,0 +1,167 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: jobs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimJob = `-- name: ClaimJob :one
+update jobs
+set status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+where id = (
+    select id from jobs
+    where status = 'pending' and run_at <= NOW() and kind = any($1::text[])
+    order by run_at
+    limit 1
+    for update skip locked
+)
+RETURNING id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_at, finished_at, last_error, unique_key
+`
+
+func (q *Queries) ClaimJob(ctx context.Context, kinds []string) (Job, error) {
+	row := q.db.QueryRowContext(ctx, claimJob, pq.Array(kinds))
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.FinishedAt,
+		&i.LastError,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const completeJob = `-- name: CompleteJob :exec
+update jobs
+set status = 'succeeded', locked_at = null, finished_at = NOW(), last_error = null, updated_at = NOW()
+where id = $1
+`
+
+func (q *Queries) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, completeJob, id)
+	return err
+}
+
+const deleteFinishedJobs = `-- name: DeleteFinishedJobs :execrows
+delete from jobs
+where status in ('succeeded', 'failed') and finished_at < $1::timestamp
+`
+
+func (q *Queries) DeleteFinishedJobs(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFinishedJobs, finishedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueJob = `-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
+)
+ON CONFLICT (unique_key) WHERE status in ('pending', 'running') DO NOTHING
+RETURNING id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_at, finished_at, last_error, unique_key
+`
+
+type EnqueueJobParams struct {
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	UniqueKey   sql.NullString  `json:"unique_key"`
+}
+
+// Returns no rows when a pending or running job already has the unique key.
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, enqueueJob,
+		arg.Kind,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.RunAt,
+		arg.UniqueKey,
+	)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.FinishedAt,
+		&i.LastError,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const failJob = `-- name: FailJob :exec
+update jobs
+set status = 'failed', locked_at = null, finished_at = NOW(), last_error = $2, updated_at = NOW()
+where id = $1
+`
+
+type FailJobParams struct {
+	ID        uuid.UUID      `json:"id"`
+	LastError sql.NullString `json:"last_error"`
+}
+
+func (q *Queries) FailJob(ctx context.Context, arg FailJobParams) error {
+	_, err := q.db.ExecContext(ctx, failJob, arg.ID, arg.LastError)
+	return err
+}
+
+const releaseStaleJobs = `-- name: ReleaseStaleJobs :execrows
+update jobs
+set status = 'pending', locked_at = null, updated_at = NOW()
+where status = 'running' and locked_at < $1::timestamp
+`
+
+// Puts jobs whose worker died mid-run back in the queue.
+func (q *Queries) ReleaseStaleJobs(ctx context.Context, staleBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, releaseStaleJobs, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryJob = `-- name: RetryJob :exec
+update jobs
+set status = 'pending', locked_at = null, run_at = $2, last_error = $3, updated_at = NOW()
+where id = $1
+`
+
+type RetryJobParams struct {
+	ID        uuid.UUID      `json:"id"`
+	RunAt     time.Time      `json:"run_at"`
+	LastError sql.NullString `json:"last_error"`
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.ExecContext(ctx, retryJob, arg.ID, arg.RunAt, arg.LastError)
+	return err
+}
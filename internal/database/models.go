@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +20,88 @@ type Chirp struct {
 	UserID    uuid.UUID `json:"user_id"`
 }
 
+type ChirpImport struct {
+	UserID     uuid.UUID `json:"user_id"`
+	SourceID   string    `json:"source_id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+type DataExport struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Status      string         `json:"status"`
+	Archive     []byte         `json:"archive"`
+	Error       sql.NullString `json:"error"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+}
+
+type HiddenChirp struct {
+	ChirpID  uuid.UUID     `json:"chirp_id"`
+	HiddenAt time.Time     `json:"hidden_at"`
+	HiddenBy uuid.NullUUID `json:"hidden_by"`
+	Reason   string        `json:"reason"`
+	Silent   bool          `json:"silent"`
+}
+
+type Job struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedAt    sql.NullTime    `json:"locked_at"`
+	FinishedAt  sql.NullTime    `json:"finished_at"`
+	LastError   sql.NullString  `json:"last_error"`
+	UniqueKey   sql.NullString  `json:"unique_key"`
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID     `json:"id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	ActorID       uuid.NullUUID `json:"actor_id"`
+	ReportID      uuid.NullUUID `json:"report_id"`
+	Action        string        `json:"action"`
+	TargetUserID  uuid.NullUUID `json:"target_user_id"`
+	TargetChirpID uuid.NullUUID `json:"target_chirp_id"`
+	Reason        string        `json:"reason"`
+}
+
+type ModerationFlag struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ChirpID    uuid.UUID    `json:"chirp_id"`
+	Source     string       `json:"source"`
+	Reason     string       `json:"reason"`
+	ResolvedAt sql.NullTime `json:"resolved_at"`
+}
+
+type ModerationWord struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+}
+
+type PinnedChirp struct {
+	UserID   uuid.UUID `json:"user_id"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	PinnedAt time.Time `json:"pinned_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type RefreshToken struct {
 	Token     string       `json:"token"`
 	CreatedAt time.Time    `json:"created_at"`
@@ -28,6 +111,46 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime `json:"revoked_at"`
 }
 
+type Report struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	ChirpID     uuid.NullUUID  `json:"chirp_id"`
+	ChirpBody   string         `json:"chirp_body"`
+	ChirpUserID uuid.UUID      `json:"chirp_user_id"`
+	ReporterID  uuid.UUID      `json:"reporter_id"`
+	Reason      string         `json:"reason"`
+	Details     string         `json:"details"`
+	Status      string         `json:"status"`
+	ClaimedBy   uuid.NullUUID  `json:"claimed_by"`
+	ClaimedAt   sql.NullTime   `json:"claimed_at"`
+	ResolvedBy  uuid.NullUUID  `json:"resolved_by"`
+	ResolvedAt  sql.NullTime   `json:"resolved_at"`
+	Resolution  sql.NullString `json:"resolution"`
+}
+
+type SpamDetection struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	Body      string        `json:"body"`
+	Reasons   []string      `json:"reasons"`
+	Action    string        `json:"action"`
+}
+
+type Subscription struct {
+	ID                uuid.UUID     `json:"id"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UserID            uuid.UUID     `json:"user_id"`
+	WebhookEventID    uuid.NullUUID `json:"webhook_event_id"`
+	Event             string        `json:"event"`
+	FromStatus        string        `json:"from_status"`
+	ToStatus          string        `json:"to_status"`
+	PreviousExpiresAt sql.NullTime  `json:"previous_expires_at"`
+	ExpiresAt         sql.NullTime  `json:"expires_at"`
+}
+
 type User struct {
 	ID                 uuid.UUID    `json:"id"`
 	CreatedAt          time.Time    `json:"created_at"`
@@ -35,4 +158,57 @@ type User struct {
 	Email              string       `json:"email"`
 	HashedPassword     string       `json:"hashed_password"`
 	ChirpyRedExpiresAt sql.NullTime `json:"chirpy_red_expires_at"`
+	DeletedAt          sql.NullTime `json:"deleted_at"`
+	SuspendedUntil     sql.NullTime `json:"suspended_until"`
+	Role               string       `json:"role"`
+	BannedAt           sql.NullTime `json:"banned_at"`
+	ShadowBannedAt     sql.NullTime `json:"shadow_banned_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventType      string          `json:"event_type"`
+	Payload        string          `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32   `json:"last_status_code"`
+	LastError      sql.NullString  `json:"last_error"`
+	TraceContext   json.RawMessage `json:"trace_context"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID      `json:"id"`
+	DeliveryID  uuid.UUID      `json:"delivery_id"`
+	AttemptedAt time.Time      `json:"attempted_at"`
+	DurationMs  int32          `json:"duration_ms"`
+	StatusCode  sql.NullInt32  `json:"status_code"`
+	Error       sql.NullString `json:"error"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Provider    string         `json:"provider"`
+	EventID     sql.NullString `json:"event_id"`
+	EventType   string         `json:"event_type"`
+	Payload     string         `json:"payload"`
+	Status      string         `json:"status"`
+	Error       sql.NullString `json:"error"`
+	Attempts    int32          `json:"attempts"`
+	ProcessedAt sql.NullTime   `json:"processed_at"`
 }
@@ -0,0 +1,166 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createModerationFlag = `-- name: CreateModerationFlag :one
+INSERT INTO moderation_flags (id, created_at, chirp_id, source, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3
+)
+RETURNING id, created_at, chirp_id, source, reason, resolved_at
+`
+
+type CreateModerationFlagParams struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Source  string    `json:"source"`
+	Reason  string    `json:"reason"`
+}
+
+func (q *Queries) CreateModerationFlag(ctx context.Context, arg CreateModerationFlagParams) (ModerationFlag, error) {
+	row := q.db.QueryRowContext(ctx, createModerationFlag, arg.ChirpID, arg.Source, arg.Reason)
+	var i ModerationFlag
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ChirpID,
+		&i.Source,
+		&i.Reason,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const deleteModerationWord = `-- name: DeleteModerationWord :execrows
+delete from moderation_words where id = $1
+`
+
+func (q *Queries) DeleteModerationWord(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteModerationWord, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listModerationWords = `-- name: ListModerationWords :many
+select id, created_at, updated_at, term, action from moderation_words order by term
+`
+
+func (q *Queries) ListModerationWords(ctx context.Context) ([]ModerationWord, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationWords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationWord
+	for rows.Next() {
+		var i ModerationWord
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Term,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenModerationFlags = `-- name: ListOpenModerationFlags :many
+select moderation_flags.id, moderation_flags.created_at, moderation_flags.chirp_id, moderation_flags.source, moderation_flags.reason, moderation_flags.resolved_at, chirps.body, chirps.user_id
+from moderation_flags
+join chirps on chirps.id = moderation_flags.chirp_id
+where moderation_flags.resolved_at is null
+order by moderation_flags.created_at
+`
+
+type ListOpenModerationFlagsRow struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ChirpID    uuid.UUID    `json:"chirp_id"`
+	Source     string       `json:"source"`
+	Reason     string       `json:"reason"`
+	ResolvedAt sql.NullTime `json:"resolved_at"`
+	Body       string       `json:"body"`
+	UserID     uuid.UUID    `json:"user_id"`
+}
+
+func (q *Queries) ListOpenModerationFlags(ctx context.Context) ([]ListOpenModerationFlagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listOpenModerationFlags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOpenModerationFlagsRow
+	for rows.Next() {
+		var i ListOpenModerationFlagsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ChirpID,
+			&i.Source,
+			&i.Reason,
+			&i.ResolvedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertModerationWord = `-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (id, created_at, updated_at, term, action)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2
+)
+ON CONFLICT (term) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING id, created_at, updated_at, term, action
+`
+
+type UpsertModerationWordParams struct {
+	Term   string `json:"term"`
+	Action string `json:"action"`
+}
+
+func (q *Queries) UpsertModerationWord(ctx context.Context, arg UpsertModerationWordParams) (ModerationWord, error) {
+	row := q.db.QueryRowContext(ctx, upsertModerationWord, arg.Term, arg.Action)
+	var i ModerationWord
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Term,
+		&i.Action,
+	)
+	return i, err
+}
@@ -0,0 +1,431 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: outbound_webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+update webhook_deliveries
+set next_attempt_at = $1::timestamp, updated_at = NOW()
+where id in (
+    select id from webhook_deliveries
+    where status = 'pending' and next_attempt_at <= NOW()
+    order by next_attempt_at
+    limit $2
+    for update skip locked
+)
+RETURNING id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, trace_context
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time `json:"lease_until"`
+	BatchSize  int32     `json:"batch_size"`
+}
+
+// Leases due deliveries by pushing their next attempt into the future, so
+// that a delivery whose worker crashes is picked up again once the lease
+// runs out.
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.TraceContext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at, trace_context)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, NOW(), $4
+)
+RETURNING id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, trace_context
+`
+
+type CreateWebhookDeliveryParams struct {
+	EndpointID   uuid.UUID       `json:"endpoint_id"`
+	EventType    string          `json:"event_type"`
+	Payload      string          `json:"payload"`
+	TraceContext json.RawMessage `json:"trace_context"`
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.EndpointID,
+		arg.EventType,
+		arg.Payload,
+		arg.TraceContext,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.TraceContext,
+	)
+	return i, err
+}
+
+const createWebhookDeliveryAttempt = `-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, duration_ms, status_code, error)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, $5
+)
+`
+
+type CreateWebhookDeliveryAttemptParams struct {
+	DeliveryID  uuid.UUID      `json:"delivery_id"`
+	AttemptedAt time.Time      `json:"attempted_at"`
+	DurationMs  int32          `json:"duration_ms"`
+	StatusCode  sql.NullInt32  `json:"status_code"`
+	Error       sql.NullString `json:"error"`
+}
+
+func (q *Queries) CreateWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDeliveryAttempt,
+		arg.DeliveryID,
+		arg.AttemptedAt,
+		arg.DurationMs,
+		arg.StatusCode,
+		arg.Error,
+	)
+	return err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
+)
+RETURNING id, created_at, updated_at, user_id, url, secret, events
+`
+
+type CreateWebhookEndpointParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Url    string    `json:"url"`
+	Secret string    `json:"secret"`
+	Events []string  `json:"events"`
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEndpoint,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :execrows
+delete from webhook_endpoints where id = $1 and user_id = $2
+`
+
+type DeleteWebhookEndpointParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookEndpoint, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at, trace_context)
+select gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, $1::text, $2::text, NOW(), $3::jsonb
+from webhook_endpoints
+where webhook_endpoints.user_id = $4 and $1::text = any(webhook_endpoints.events)
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	EventType    string          `json:"event_type"`
+	Payload      string          `json:"payload"`
+	TraceContext json.RawMessage `json:"trace_context"`
+	UserID       uuid.UUID       `json:"user_id"`
+}
+
+// Queues a delivery of the event to every endpoint of the user subscribed
+// to it.
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookDeliveries,
+		arg.EventType,
+		arg.Payload,
+		arg.TraceContext,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishWebhookDeliveryAttempt = `-- name: FinishWebhookDeliveryAttempt :exec
+update webhook_deliveries
+set status = $2, attempts = attempts + 1, next_attempt_at = $3,
+    last_status_code = $4, last_error = $5, updated_at = NOW()
+where id = $1
+`
+
+type FinishWebhookDeliveryAttemptParams struct {
+	ID             uuid.UUID      `json:"id"`
+	Status         string         `json:"status"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastStatusCode sql.NullInt32  `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+}
+
+func (q *Queries) FinishWebhookDeliveryAttempt(ctx context.Context, arg FinishWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, finishWebhookDeliveryAttempt,
+		arg.ID,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+	)
+	return err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+select id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, trace_context from webhook_deliveries where id = $1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.TraceContext,
+	)
+	return i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+select id, created_at, updated_at, user_id, url, secret, events from webhook_endpoints where id = $1
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEndpoint, id)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const listWebhookDeliveriesForEndpoint = `-- name: ListWebhookDeliveriesForEndpoint :many
+select id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, trace_context from webhook_deliveries
+where endpoint_id = $1
+order by created_at desc
+limit 100
+`
+
+func (q *Queries) ListWebhookDeliveriesForEndpoint(ctx context.Context, endpointID uuid.UUID) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveriesForEndpoint, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.TraceContext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveryAttempts = `-- name: ListWebhookDeliveryAttempts :many
+select id, delivery_id, attempted_at, duration_ms, status_code, error from webhook_delivery_attempts
+where delivery_id = $1
+order by attempted_at
+`
+
+func (q *Queries) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]WebhookDeliveryAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveryAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.AttemptedAt,
+			&i.DurationMs,
+			&i.StatusCode,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsForUser = `-- name: ListWebhookEndpointsForUser :many
+select id, created_at, updated_at, user_id, url, secret, events from webhook_endpoints where user_id = $1 order by created_at
+`
+
+func (q *Queries) ListWebhookEndpointsForUser(ctx context.Context, userID uuid.UUID) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEndpointsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const redeliverWebhookDelivery = `-- name: RedeliverWebhookDelivery :one
+update webhook_deliveries
+set status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+where id = $1 and status = 'dead'
+RETURNING id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, trace_context
+`
+
+// Attempts start over, so that the delivery gets its retries again.
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, redeliverWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.TraceContext,
+	)
+	return i, err
+}
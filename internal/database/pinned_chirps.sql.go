@@ -0,0 +1,105 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: pinned_chirps.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const countPinnedChirps = `-- name: CountPinnedChirps :one
+select count(*) from pinned_chirps where user_id = $1
+`
+
+func (q *Queries) CountPinnedChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getPinnedChirpsForUser = `-- name: GetPinnedChirpsForUser :many
+select chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id from pinned_chirps
+join chirps on chirps.id = pinned_chirps.chirp_id
+join users on users.id = chirps.user_id
+where pinned_chirps.user_id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = $2::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = $2::uuid)
+order by pinned_chirps.pinned_at desc
+limit $3::int
+`
+
+type GetPinnedChirpsForUserParams struct {
+	UserID    uuid.UUID     `json:"user_id"`
+	ViewerID  uuid.NullUUID `json:"viewer_id"`
+	MaxPinned int32         `json:"max_pinned"`
+}
+
+func (q *Queries) GetPinnedChirpsForUser(ctx context.Context, arg GetPinnedChirpsForUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedChirpsForUser, arg.UserID, arg.ViewerID, arg.MaxPinned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pinChirp = `-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+`
+
+type PinChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, pinChirp, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+delete from pinned_chirps where user_id = $1 and chirp_id = $2
+`
+
+type UnpinChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
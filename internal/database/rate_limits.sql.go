@@ -0,0 +1,68 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: rate_limits.sql
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const deleteIdleRateLimitBuckets = `-- name: DeleteIdleRateLimitBuckets :execrows
+delete from rate_limit_buckets where updated_at < $1
+`
+
+func (q *Queries) DeleteIdleRateLimitBuckets(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteIdleRateLimitBuckets, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const ensureRateLimitBucket = `-- name: EnsureRateLimitBucket :exec
+INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING
+`
+
+type EnsureRateLimitBucketParams struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) EnsureRateLimitBucket(ctx context.Context, arg EnsureRateLimitBucketParams) error {
+	_, err := q.db.ExecContext(ctx, ensureRateLimitBucket, arg.Key, arg.Tokens, arg.UpdatedAt)
+	return err
+}
+
+const getRateLimitBucketForUpdate = `-- name: GetRateLimitBucketForUpdate :one
+select key, tokens, updated_at from rate_limit_buckets where key = $1 FOR UPDATE
+`
+
+func (q *Queries) GetRateLimitBucketForUpdate(ctx context.Context, key string) (RateLimitBucket, error) {
+	row := q.db.QueryRowContext(ctx, getRateLimitBucketForUpdate, key)
+	var i RateLimitBucket
+	err := row.Scan(&i.Key, &i.Tokens, &i.UpdatedAt)
+	return i, err
+}
+
+const updateRateLimitBucket = `-- name: UpdateRateLimitBucket :exec
+update rate_limit_buckets
+set tokens = $2, updated_at = $3
+where key = $1
+`
+
+type UpdateRateLimitBucketParams struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) UpdateRateLimitBucket(ctx context.Context, arg UpdateRateLimitBucketParams) error {
+	_, err := q.db.ExecContext(ctx, updateRateLimitBucket, arg.Key, arg.Tokens, arg.UpdatedAt)
+	return err
+}
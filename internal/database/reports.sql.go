@@ -0,0 +1,331 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: reports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimReport = `-- name: ClaimReport :one
+update reports
+set status = 'claimed', claimed_by = $2, claimed_at = NOW(), updated_at = NOW()
+where id = $1 and status = 'open'
+RETURNING id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status, claimed_by, claimed_at, resolved_by, resolved_at, resolution
+`
+
+type ClaimReportParams struct {
+	ID        uuid.UUID     `json:"id"`
+	ClaimedBy uuid.NullUUID `json:"claimed_by"`
+}
+
+func (q *Queries) ClaimReport(ctx context.Context, arg ClaimReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, claimReport, arg.ID, arg.ClaimedBy)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ChirpBody,
+		&i.ChirpUserID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.ClaimedAt,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+		&i.Resolution,
+	)
+	return i, err
+}
+
+const createModerationAction = `-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, actor_id, report_id, action, target_user_id, target_chirp_id, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6
+)
+RETURNING id, created_at, actor_id, report_id, action, target_user_id, target_chirp_id, reason
+`
+
+type CreateModerationActionParams struct {
+	ActorID       uuid.NullUUID `json:"actor_id"`
+	ReportID      uuid.NullUUID `json:"report_id"`
+	Action        string        `json:"action"`
+	TargetUserID  uuid.NullUUID `json:"target_user_id"`
+	TargetChirpID uuid.NullUUID `json:"target_chirp_id"`
+	Reason        string        `json:"reason"`
+}
+
+func (q *Queries) CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, createModerationAction,
+		arg.ActorID,
+		arg.ReportID,
+		arg.Action,
+		arg.TargetUserID,
+		arg.TargetChirpID,
+		arg.Reason,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ActorID,
+		&i.ReportID,
+		&i.Action,
+		&i.TargetUserID,
+		&i.TargetChirpID,
+		&i.Reason,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6, 'open'
+)
+RETURNING id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status, claimed_by, claimed_at, resolved_by, resolved_at, resolution
+`
+
+type CreateReportParams struct {
+	ChirpID     uuid.NullUUID `json:"chirp_id"`
+	ChirpBody   string        `json:"chirp_body"`
+	ChirpUserID uuid.UUID     `json:"chirp_user_id"`
+	ReporterID  uuid.UUID     `json:"reporter_id"`
+	Reason      string        `json:"reason"`
+	Details     string        `json:"details"`
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ChirpID,
+		arg.ChirpBody,
+		arg.ChirpUserID,
+		arg.ReporterID,
+		arg.Reason,
+		arg.Details,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ChirpBody,
+		&i.ChirpUserID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.ClaimedAt,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+		&i.Resolution,
+	)
+	return i, err
+}
+
+const getReport = `-- name: GetReport :one
+select id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status, claimed_by, claimed_at, resolved_by, resolved_at, resolution from reports where id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ChirpBody,
+		&i.ChirpUserID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.ClaimedAt,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+		&i.Resolution,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :exec
+INSERT INTO hidden_chirps (chirp_id, hidden_at, hidden_by, reason)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (chirp_id) DO NOTHING
+`
+
+type HideChirpParams struct {
+	ChirpID  uuid.UUID     `json:"chirp_id"`
+	HiddenBy uuid.NullUUID `json:"hidden_by"`
+	Reason   string        `json:"reason"`
+}
+
+func (q *Queries) HideChirp(ctx context.Context, arg HideChirpParams) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, arg.ChirpID, arg.HiddenBy, arg.Reason)
+	return err
+}
+
+const listModerationActionsForReport = `-- name: ListModerationActionsForReport :many
+select id, created_at, actor_id, report_id, action, target_user_id, target_chirp_id, reason from moderation_actions where report_id = $1 order by created_at
+`
+
+func (q *Queries) ListModerationActionsForReport(ctx context.Context, reportID uuid.NullUUID) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationActionsForReport, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.ReportID,
+			&i.Action,
+			&i.TargetUserID,
+			&i.TargetChirpID,
+			&i.Reason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReportsByStatus = `-- name: ListReportsByStatus :many
+select id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status, claimed_by, claimed_at, resolved_by, resolved_at, resolution from reports where status = $1 order by created_at
+`
+
+func (q *Queries) ListReportsByStatus(ctx context.Context, status string) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, listReportsByStatus, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ChirpID,
+			&i.ChirpBody,
+			&i.ChirpUserID,
+			&i.ReporterID,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.ClaimedBy,
+			&i.ClaimedAt,
+			&i.ResolvedBy,
+			&i.ResolvedAt,
+			&i.Resolution,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWarningsForUser = `-- name: ListWarningsForUser :many
+select id, created_at, reason from moderation_actions
+where target_user_id = $1 and action = 'warn_user'
+order by created_at desc
+`
+
+type ListWarningsForUserRow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Reason    string    `json:"reason"`
+}
+
+func (q *Queries) ListWarningsForUser(ctx context.Context, targetUserID uuid.NullUUID) ([]ListWarningsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWarningsForUser, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWarningsForUserRow
+	for rows.Next() {
+		var i ListWarningsForUserRow
+		if err := rows.Scan(&i.ID, &i.CreatedAt, &i.Reason); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveReport = `-- name: ResolveReport :one
+update reports
+set status = 'resolved', resolved_by = $2, resolved_at = NOW(), resolution = $3, updated_at = NOW()
+where id = $1 and (status = 'open' or (status = 'claimed' and claimed_by = $2))
+RETURNING id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status, claimed_by, claimed_at, resolved_by, resolved_at, resolution
+`
+
+type ResolveReportParams struct {
+	ID         uuid.UUID      `json:"id"`
+	ResolvedBy uuid.NullUUID  `json:"resolved_by"`
+	Resolution sql.NullString `json:"resolution"`
+}
+
+// A claimed report can only be resolved by whoever claimed it.
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resolveReport, arg.ID, arg.ResolvedBy, arg.Resolution)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ChirpID,
+		&i.ChirpBody,
+		&i.ChirpUserID,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.ClaimedAt,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+		&i.Resolution,
+	)
+	return i, err
+}
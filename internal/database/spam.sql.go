@@ -0,0 +1,104 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: spam.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createSpamDetection = `-- name: CreateSpamDetection :one
+INSERT INTO spam_detections (id, created_at, user_id, chirp_id, body, reasons, action)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5
+)
+RETURNING id, created_at, user_id, chirp_id, body, reasons, action
+`
+
+type CreateSpamDetectionParams struct {
+	UserID  uuid.UUID     `json:"user_id"`
+	ChirpID uuid.NullUUID `json:"chirp_id"`
+	Body    string        `json:"body"`
+	Reasons []string      `json:"reasons"`
+	Action  string        `json:"action"`
+}
+
+func (q *Queries) CreateSpamDetection(ctx context.Context, arg CreateSpamDetectionParams) (SpamDetection, error) {
+	row := q.db.QueryRowContext(ctx, createSpamDetection,
+		arg.UserID,
+		arg.ChirpID,
+		arg.Body,
+		pq.Array(arg.Reasons),
+		arg.Action,
+	)
+	var i SpamDetection
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Body,
+		pq.Array(&i.Reasons),
+		&i.Action,
+	)
+	return i, err
+}
+
+const hideChirpSilently = `-- name: HideChirpSilently :exec
+INSERT INTO hidden_chirps (chirp_id, hidden_at, reason, silent)
+VALUES ($1, NOW(), $2, true)
+ON CONFLICT (chirp_id) DO NOTHING
+`
+
+type HideChirpSilentlyParams struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Reason  string    `json:"reason"`
+}
+
+func (q *Queries) HideChirpSilently(ctx context.Context, arg HideChirpSilentlyParams) error {
+	_, err := q.db.ExecContext(ctx, hideChirpSilently, arg.ChirpID, arg.Reason)
+	return err
+}
+
+const listSpamDetections = `-- name: ListSpamDetections :many
+select id, created_at, user_id, chirp_id, body, reasons, action from spam_detections
+where created_at > $1::timestamp
+order by created_at desc
+`
+
+func (q *Queries) ListSpamDetections(ctx context.Context, since time.Time) ([]SpamDetection, error) {
+	rows, err := q.db.QueryContext(ctx, listSpamDetections, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SpamDetection
+	for rows.Next() {
+		var i SpamDetection
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Body,
+			pq.Array(&i.Reasons),
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,162 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createSubscriptionChange = `-- name: CreateSubscriptionChange :one
+INSERT INTO subscriptions (id, created_at, user_id, webhook_event_id, event, from_status, to_status, previous_expires_at, expires_at)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, created_at, user_id, webhook_event_id, event, from_status, to_status, previous_expires_at, expires_at
+`
+
+type CreateSubscriptionChangeParams struct {
+	UserID            uuid.UUID     `json:"user_id"`
+	WebhookEventID    uuid.NullUUID `json:"webhook_event_id"`
+	Event             string        `json:"event"`
+	FromStatus        string        `json:"from_status"`
+	ToStatus          string        `json:"to_status"`
+	PreviousExpiresAt sql.NullTime  `json:"previous_expires_at"`
+	ExpiresAt         sql.NullTime  `json:"expires_at"`
+}
+
+func (q *Queries) CreateSubscriptionChange(ctx context.Context, arg CreateSubscriptionChangeParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, createSubscriptionChange,
+		arg.UserID,
+		arg.WebhookEventID,
+		arg.Event,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.PreviousExpiresAt,
+		arg.ExpiresAt,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.WebhookEventID,
+		&i.Event,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.PreviousExpiresAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getLatestSubscriptionChange = `-- name: GetLatestSubscriptionChange :one
+select id, created_at, user_id, webhook_event_id, event, from_status, to_status, previous_expires_at, expires_at from subscriptions
+where user_id = $1
+order by created_at desc
+limit 1
+`
+
+func (q *Queries) GetLatestSubscriptionChange(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getLatestSubscriptionChange, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.WebhookEventID,
+		&i.Event,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.PreviousExpiresAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const listLapsedChirpyRedUsers = `-- name: ListLapsedChirpyRedUsers :many
+select users.id, users.chirpy_red_expires_at from users
+where users.chirpy_red_expires_at <= NOW() and users.deleted_at is null
+  and coalesce((
+    select to_status from subscriptions
+    where subscriptions.user_id = users.id
+    order by created_at desc
+    limit 1
+  ), 'active') in ('active', 'past_due')
+order by users.chirpy_red_expires_at
+limit 500
+`
+
+type ListLapsedChirpyRedUsersRow struct {
+	ID                 uuid.UUID    `json:"id"`
+	ChirpyRedExpiresAt sql.NullTime `json:"chirpy_red_expires_at"`
+}
+
+// Users whose Chirpy Red ran out without a cancellation or refund ending
+// it, and who haven't been marked expired yet.
+func (q *Queries) ListLapsedChirpyRedUsers(ctx context.Context) ([]ListLapsedChirpyRedUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLapsedChirpyRedUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLapsedChirpyRedUsersRow
+	for rows.Next() {
+		var i ListLapsedChirpyRedUsersRow
+		if err := rows.Scan(&i.ID, &i.ChirpyRedExpiresAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubscriptionChangesForUser = `-- name: ListSubscriptionChangesForUser :many
+select id, created_at, user_id, webhook_event_id, event, from_status, to_status, previous_expires_at, expires_at from subscriptions
+where user_id = $1
+order by created_at
+`
+
+func (q *Queries) ListSubscriptionChangesForUser(ctx context.Context, userID uuid.UUID) ([]Subscription, error) {
+	rows, err := q.db.QueryContext(ctx, listSubscriptionChangesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.WebhookEventID,
+			&i.Event,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.PreviousExpiresAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
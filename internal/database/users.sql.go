@@ -18,7 +18,7 @@ INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
     gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at
+RETURNING id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at, deleted_at, suspended_until, role, banned_at, shadow_banned_at
 `
 
 type CreateUserParams struct {
@@ -36,6 +36,11 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.BannedAt,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -50,7 +55,7 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-select id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at from users where email = $1
+select id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at, deleted_at, suspended_until, role, banned_at, shadow_banned_at from users where email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -63,10 +68,252 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.BannedAt,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+select id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at, deleted_at, suspended_until, role, banned_at, shadow_banned_at from users where id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.BannedAt,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const getUserByIDForUpdate = `-- name: GetUserByIDForUpdate :one
+select id, created_at, updated_at, email, hashed_password, chirpy_red_expires_at, deleted_at, suspended_until, role, banned_at, shadow_banned_at from users where id = $1 for update
+`
+
+func (q *Queries) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.BannedAt,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const isActiveUser = `-- name: IsActiveUser :one
+select exists(select 1 from users where id = $1 and deleted_at is null)
+`
+
+// Reports whether the user exists and isn't scheduled for deletion.
+func (q *Queries) IsActiveUser(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isActiveUser, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listUsers = `-- name: ListUsers :many
+select id, created_at, updated_at, email, role, chirpy_red_expires_at, suspended_until, banned_at, shadow_banned_at, deleted_at
+from users
+order by created_at
+`
+
+type ListUsersRow struct {
+	ID                 uuid.UUID    `json:"id"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+	Email              string       `json:"email"`
+	Role               string       `json:"role"`
+	ChirpyRedExpiresAt sql.NullTime `json:"chirpy_red_expires_at"`
+	SuspendedUntil     sql.NullTime `json:"suspended_until"`
+	BannedAt           sql.NullTime `json:"banned_at"`
+	ShadowBannedAt     sql.NullTime `json:"shadow_banned_at"`
+	DeletedAt          sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.Role,
+			&i.ChirpyRedExpiresAt,
+			&i.SuspendedUntil,
+			&i.BannedAt,
+			&i.ShadowBannedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+delete from users
+where deleted_at is not null and deleted_at <= $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :one
+update users
+set deleted_at = null, updated_at = NOW()
+where id = $1 and deleted_at is not null and deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, email, chirpy_red_expires_at, deleted_at
+`
+
+type RestoreUserParams struct {
+	ID           uuid.UUID `json:"id"`
+	DeletedAfter time.Time `json:"deleted_after"`
+}
+
+type RestoreUserRow struct {
+	ID                 uuid.UUID    `json:"id"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+	Email              string       `json:"email"`
+	ChirpyRedExpiresAt sql.NullTime `json:"chirpy_red_expires_at"`
+	DeletedAt          sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (RestoreUserRow, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.DeletedAfter)
+	var i RestoreUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+update users
+set deleted_at = NOW(), updated_at = NOW()
+where id = $1 and deleted_at is null
+RETURNING id, created_at, updated_at, email, chirpy_red_expires_at, deleted_at
+`
+
+type ScheduleUserDeletionRow struct {
+	ID                 uuid.UUID    `json:"id"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+	Email              string       `json:"email"`
+	ChirpyRedExpiresAt sql.NullTime `json:"chirpy_red_expires_at"`
+	DeletedAt          sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, id uuid.UUID) (ScheduleUserDeletionRow, error) {
+	row := q.db.QueryRowContext(ctx, scheduleUserDeletion, id)
+	var i ScheduleUserDeletionRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.ChirpyRedExpiresAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserBan = `-- name: SetUserBan :exec
+update users
+set banned_at = $2, updated_at = NOW()
+where id = $1
+`
+
+type SetUserBanParams struct {
+	ID       uuid.UUID    `json:"id"`
+	BannedAt sql.NullTime `json:"banned_at"`
+}
+
+func (q *Queries) SetUserBan(ctx context.Context, arg SetUserBanParams) error {
+	_, err := q.db.ExecContext(ctx, setUserBan, arg.ID, arg.BannedAt)
+	return err
+}
+
+const setUserShadowBan = `-- name: SetUserShadowBan :exec
+update users
+set shadow_banned_at = $2, updated_at = NOW()
+where id = $1
+`
+
+type SetUserShadowBanParams struct {
+	ID             uuid.UUID    `json:"id"`
+	ShadowBannedAt sql.NullTime `json:"shadow_banned_at"`
+}
+
+func (q *Queries) SetUserShadowBan(ctx context.Context, arg SetUserShadowBanParams) error {
+	_, err := q.db.ExecContext(ctx, setUserShadowBan, arg.ID, arg.ShadowBannedAt)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+update users
+set suspended_until = $2, updated_at = NOW()
+where id = $1
+`
+
+type SuspendUserParams struct {
+	ID             uuid.UUID    `json:"id"`
+	SuspendedUntil sql.NullTime `json:"suspended_until"`
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil)
+	return err
+}
+
 const updateUserChirpyRed = `-- name: UpdateUserChirpyRed :one
 update users
 set updated_at=NOW(), chirpy_red_expires_at=$2
@@ -133,3 +380,36 @@ func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUser
 	)
 	return i, err
 }
+
+const updateUserRole = `-- name: UpdateUserRole :one
+update users
+set role = $2, updated_at = NOW()
+where id = $1
+RETURNING id, created_at, updated_at, email, role
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+type UpdateUserRoleRow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (UpdateUserRoleRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i UpdateUserRoleRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.Role,
+	)
+	return i, err
+}
@@ -0,0 +1,201 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, 'processing'
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+`
+
+type CreateWebhookEventParams struct {
+	Provider  string         `json:"provider"`
+	EventID   sql.NullString `json:"event_id"`
+	EventType string         `json:"event_type"`
+	Payload   string         `json:"payload"`
+}
+
+// Returns no rows when the provider already sent an event with this ID.
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEvent,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const finishWebhookEvent = `-- name: FinishWebhookEvent :exec
+update webhook_events
+set status = $2, error = $3, updated_at = NOW(),
+    processed_at = case when $2 = 'failed' then processed_at else NOW() end
+where id = $1
+`
+
+type FinishWebhookEventParams struct {
+	ID     uuid.UUID      `json:"id"`
+	Status string         `json:"status"`
+	Error  sql.NullString `json:"error"`
+}
+
+func (q *Queries) FinishWebhookEvent(ctx context.Context, arg FinishWebhookEventParams) error {
+	_, err := q.db.ExecContext(ctx, finishWebhookEvent, arg.ID, arg.Status, arg.Error)
+	return err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+select id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at from webhook_events where id = $1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEventByEventID = `-- name: GetWebhookEventByEventID :one
+select id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at from webhook_events where provider = $1 and event_id = $2
+`
+
+type GetWebhookEventByEventIDParams struct {
+	Provider string         `json:"provider"`
+	EventID  sql.NullString `json:"event_id"`
+}
+
+func (q *Queries) GetWebhookEventByEventID(ctx context.Context, arg GetWebhookEventByEventIDParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEventByEventID, arg.Provider, arg.EventID)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+select id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at from webhook_events
+where $1::text is null or status = $1::text
+order by created_at desc
+limit 200
+`
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, status sql.NullString) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEvents, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Error,
+			&i.Attempts,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retryWebhookEvent = `-- name: RetryWebhookEvent :one
+update webhook_events
+set status = 'processing', attempts = attempts + 1, error = null, updated_at = NOW()
+where id = $1
+  and (status = 'failed' or (status = 'processing' and updated_at < $2::timestamp))
+RETURNING id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+`
+
+type RetryWebhookEventParams struct {
+	ID          uuid.UUID `json:"id"`
+	StaleBefore time.Time `json:"stale_before"`
+}
+
+// Claims a failed event for another attempt, or one stuck in processing
+// since before stale_before because the attempt died. Returns no rows when
+// the event has already been handled or another attempt is in progress.
+func (q *Queries) RetryWebhookEvent(ctx context.Context, arg RetryWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, retryWebhookEvent, arg.ID, arg.StaleBefore)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,107 @@
+// Package entitlements defines what each subscription tier may do. Handlers
+// look perks up here instead of checking for Chirpy Red themselves, so that
+// perks can be changed in configuration.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type Tier string
+
+const (
+	TierFree Tier = "free"
+	TierRed  Tier = "red"
+)
+
+type Perks struct {
+	MaxChirpLength      int     `json:"max_chirp_length"`
+	EditChirps          bool    `json:"edit_chirps"`
+	MaxPinnedChirps     int     `json:"max_pinned_chirps"`
+	RateLimitMultiplier float64 `json:"rate_limit_multiplier"`
+}
+
+func (p Perks) validate() error {
+	if p.MaxChirpLength <= 0 {
+		return fmt.Errorf("max_chirp_length must be positive")
+	}
+	if p.MaxPinnedChirps < 0 {
+		return fmt.Errorf("max_pinned_chirps can't be negative")
+	}
+	if p.RateLimitMultiplier <= 0 {
+		return fmt.Errorf("rate_limit_multiplier must be positive")
+	}
+	return nil
+}
+
+type Config map[Tier]Perks
+
+func DefaultConfig() Config {
+	return Config{
+		TierFree: {
+			MaxChirpLength:      140,
+			RateLimitMultiplier: 1,
+		},
+		TierRed: {
+			MaxChirpLength:      280,
+			EditChirps:          true,
+			MaxPinnedChirps:     3,
+			RateLimitMultiplier: 3,
+		},
+	}
+}
+
+// For returns the perks of tier. Unknown tiers get the free perks.
+func (c Config) For(tier Tier) Perks {
+	if perks, ok := c[tier]; ok {
+		return perks
+	}
+	return c[TierFree]
+}
+
+// TierOf returns the tier of a user given whether their Chirpy Red is
+// active.
+func TierOf(chirpyRed bool) Tier {
+	if chirpyRed {
+		return TierRed
+	}
+	return TierFree
+}
+
+// LoadFile reads a JSON object keyed by tier. Tiers and perks left out of
+// the file keep their defaults.
+func LoadFile(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads the format of LoadFile. Each tier is decoded on top of its
+// defaults, so a file only needs to list the perks it changes.
+func Parse(r io.Reader) (Config, error) {
+	var overrides map[Tier]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&overrides); err != nil {
+		return nil, fmt.Errorf("could not parse entitlements: %w", err)
+	}
+	cfg := DefaultConfig()
+	for tier, raw := range overrides {
+		if tier != TierFree && tier != TierRed {
+			return nil, fmt.Errorf("unknown tier %q", tier)
+		}
+		perks := cfg[tier]
+		if err := json.Unmarshal(raw, &perks); err != nil {
+			return nil, fmt.Errorf("tier %s: could not parse entitlements: %w", tier, err)
+		}
+		if err := perks.validate(); err != nil {
+			return nil, fmt.Errorf("tier %s: %w", tier, err)
+		}
+		cfg[tier] = perks
+	}
+	return cfg, nil
+}
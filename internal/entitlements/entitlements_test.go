@@ -0,0 +1,71 @@
+package entitlements_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wilgnert/chirpy/internal/entitlements"
+)
+
+func TestDefaultPerks(t *testing.T) {
+	cfg := entitlements.DefaultConfig()
+	free := cfg.For(entitlements.TierOf(false))
+	red := cfg.For(entitlements.TierOf(true))
+
+	if free.MaxChirpLength != 140 || free.EditChirps || free.MaxPinnedChirps != 0 {
+		t.Errorf("unexpected free perks %+v", free)
+	}
+	if red.MaxChirpLength <= free.MaxChirpLength || !red.EditChirps || red.RateLimitMultiplier <= free.RateLimitMultiplier {
+		t.Errorf("expected red perks to improve on free, got %+v", red)
+	}
+}
+
+func TestParseOverridesTier(t *testing.T) {
+	cfg, err := entitlements.Parse(strings.NewReader(`{"red": {"max_chirp_length": 500, "edit_chirps": false, "max_pinned_chirps": 1, "rate_limit_multiplier": 2}}`))
+	if err != nil {
+		t.Fatalf("unexpected error parsing entitlements: %v", err)
+	}
+	red := cfg.For(entitlements.TierRed)
+	if red.MaxChirpLength != 500 || red.EditChirps || red.MaxPinnedChirps != 1 {
+		t.Errorf("expected red perks from the file, got %+v", red)
+	}
+	if free := cfg.For(entitlements.TierFree); free.MaxChirpLength != 140 {
+		t.Errorf("expected free perks to keep their defaults, got %+v", free)
+	}
+}
+
+func TestParsePartialOverride(t *testing.T) {
+	cfg, err := entitlements.Parse(strings.NewReader(`{"red": {"max_chirp_length": 500}}`))
+	if err != nil {
+		t.Fatalf("unexpected error parsing entitlements: %v", err)
+	}
+	red := cfg.For(entitlements.TierRed)
+	defaults := entitlements.DefaultConfig().For(entitlements.TierRed)
+	if red.MaxChirpLength != 500 {
+		t.Errorf("expected the overridden perk, got %+v", red)
+	}
+	if red.EditChirps != defaults.EditChirps || red.MaxPinnedChirps != defaults.MaxPinnedChirps || red.RateLimitMultiplier != defaults.RateLimitMultiplier {
+		t.Errorf("expected the other perks to keep their defaults %+v, got %+v", defaults, red)
+	}
+}
+
+func TestParseRejectsInvalidConfig(t *testing.T) {
+	for _, raw := range []string{
+		`{"gold": {"max_chirp_length": 500, "rate_limit_multiplier": 1}}`,
+		`{"red": {"max_chirp_length": 0, "rate_limit_multiplier": 1}}`,
+		`{"red": {"rate_limit_multiplier": 0}}`,
+		`{"red": {"max_pinned_chirps": -1}}`,
+		`not json`,
+	} {
+		if _, err := entitlements.Parse(strings.NewReader(raw)); err == nil {
+			t.Errorf("expected error parsing %s, got none", raw)
+		}
+	}
+}
+
+func TestUnknownTierGetsFreePerks(t *testing.T) {
+	cfg := entitlements.DefaultConfig()
+	if perks := cfg.For("gold"); perks != cfg.For(entitlements.TierFree) {
+		t.Errorf("expected free perks for an unknown tier, got %+v", perks)
+	}
+}
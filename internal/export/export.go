@@ -0,0 +1,134 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+type Profile struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Chirp struct {
+	ID        string    `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Session describes an active refresh token. The token itself is left out
+// so that a leaked archive cannot be used to sign in.
+type Session struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ChirpyRed struct {
+	Active    bool                 `json:"active"`
+	ExpiresAt *time.Time           `json:"expires_at"`
+	History   []SubscriptionChange `json:"history"`
+}
+
+// SubscriptionChange is one transition of the Chirpy Red subscription.
+type SubscriptionChange struct {
+	Event      string     `json:"event"`
+	FromStatus string     `json:"from_status"`
+	ToStatus   string     `json:"to_status"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	ChangedAt  time.Time  `json:"changed_at"`
+}
+
+type Data struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Profile     Profile   `json:"profile"`
+	Chirps      []Chirp   `json:"chirps"`
+	Sessions    []Session `json:"sessions"`
+	ChirpyRed   ChirpyRed `json:"chirpy_red"`
+}
+
+var page = template.Must(template.New("index.html").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Your Chirpy data</title>
+  </head>
+  <body>
+    <h1>Your Chirpy data</h1>
+    <p>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+    <h2>Profile</h2>
+    <dl>
+      <dt>ID</dt><dd>{{.Profile.ID}}</dd>
+      <dt>Email</dt><dd>{{.Profile.Email}}</dd>
+      <dt>Joined</dt><dd>{{.Profile.CreatedAt.Format "2006-01-02 15:04:05 MST"}}</dd>
+    </dl>
+
+    <h2>Chirpy Red</h2>
+    {{if .ChirpyRed.Active}}<p>Active until {{.ChirpyRed.ExpiresAt.Format "2006-01-02 15:04:05 MST"}}</p>{{else}}<p>Not active</p>{{end}}
+    {{if .ChirpyRed.History}}<ul>
+      {{range .ChirpyRed.History}}<li><time>{{.ChangedAt.Format "2006-01-02 15:04:05 MST"}}</time> {{.Event}}: {{.FromStatus}} &rarr; {{.ToStatus}}</li>
+      {{end}}
+    </ul>{{end}}
+
+    <h2>Active sessions ({{len .Sessions}})</h2>
+    <ul>
+      {{range .Sessions}}<li>Signed in {{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}, expires {{.ExpiresAt.Format "2006-01-02 15:04:05 MST"}}</li>
+      {{end}}
+    </ul>
+
+    <h2>Chirps ({{len .Chirps}})</h2>
+    <ul>
+      {{range .Chirps}}<li><time>{{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}</time> {{.Body}}</li>
+      {{end}}
+    </ul>
+  </body>
+</html>
+`))
+
+// Archive bundles data into a zip holding one JSON file per section and an
+// index.html view of the same data.
+func Archive(data Data) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", data.Profile},
+		{"chirps.json", data.Chirps},
+		{"sessions.json", data.Sessions},
+		{"chirpy_red.json", data.ChirpyRed},
+	}
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("could not add %s: %w", file.name, err)
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, fmt.Errorf("could not encode %s: %w", file.name, err)
+		}
+	}
+
+	f, err := zw.Create("index.html")
+	if err != nil {
+		return nil, fmt.Errorf("could not add index.html: %w", err)
+	}
+	if err := page.Execute(f, data); err != nil {
+		return nil, fmt.Errorf("could not render index.html: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
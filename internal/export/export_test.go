@@ -0,0 +1,97 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/export"
+)
+
+func readArchive(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("unexpected error opening archive: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("unexpected error opening %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("unexpected error reading %s: %v", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestArchiveContainsEverySection(t *testing.T) {
+	now := time.Now()
+	archive, err := export.Archive(export.Data{
+		GeneratedAt: now,
+		Profile:     export.Profile{ID: "user-id", Email: "user@example.com", CreatedAt: now, UpdatedAt: now},
+		Chirps:      []export.Chirp{{ID: "chirp-id", Body: "hello", CreatedAt: now, UpdatedAt: now}},
+		Sessions:    []export.Session{{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building archive: %v", err)
+	}
+
+	files := readArchive(t, archive)
+	for _, name := range []string{"profile.json", "chirps.json", "sessions.json", "chirpy_red.json", "index.html"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected archive to contain %s", name)
+		}
+	}
+
+	var chirps []export.Chirp
+	if err := json.Unmarshal(files["chirps.json"], &chirps); err != nil {
+		t.Fatalf("unexpected error decoding chirps.json: %v", err)
+	}
+	if len(chirps) != 1 || chirps[0].Body != "hello" {
+		t.Errorf("expected the exported chirp, got %v", chirps)
+	}
+}
+
+func TestArchiveEscapesHTML(t *testing.T) {
+	archive, err := export.Archive(export.Data{
+		Chirps: []export.Chirp{{Body: "<script>alert(1)</script>"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building archive: %v", err)
+	}
+
+	index := string(readArchive(t, archive)["index.html"])
+	if strings.Contains(index, "<script>") {
+		t.Errorf("expected chirp body to be escaped in index.html")
+	}
+}
+
+func TestArchiveIncludesSubscriptionHistory(t *testing.T) {
+	now := time.Now()
+	archive, err := export.Archive(export.Data{
+		ChirpyRed: export.ChirpyRed{
+			History: []export.SubscriptionChange{{Event: "user.upgraded", FromStatus: "none", ToStatus: "active", ChangedAt: now}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building archive: %v", err)
+	}
+
+	var red export.ChirpyRed
+	if err := json.Unmarshal(readArchive(t, archive)["chirpy_red.json"], &red); err != nil {
+		t.Fatalf("unexpected error decoding chirpy_red.json: %v", err)
+	}
+	if len(red.History) != 1 || red.History[0].ToStatus != "active" {
+		t.Errorf("expected the subscription history, got %v", red.History)
+	}
+}
@@ -0,0 +1,306 @@
+// Package jobs is a durable background job queue stored in the jobs table.
+// Workers claim jobs with SELECT ... FOR UPDATE SKIP LOCKED, so any number
+// of server instances can share the queue without running a job twice.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	baseBackoff        = 10 * time.Second
+	maxBackoff         = time.Hour
+)
+
+// Backoff is how long a job waits before its next attempt after the given
+// number of failed attempts.
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// Handler runs a job. Returning an error retries the job until it runs out
+// of attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Handle adapts a function taking a typed payload to a Handler.
+func Handle[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("could not decode payload: %w", err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type recurring struct {
+	kind     string
+	interval time.Duration
+}
+
+type Queue struct {
+	q         *database.Queries
+	handlers  map[string]Handler
+	recurring map[string]recurring
+
+	// PollInterval is how often an idle worker looks for due jobs.
+	PollInterval time.Duration
+	// LockTimeout is how long a job may run before it is assumed to belong
+	// to a dead worker and is put back in the queue.
+	LockTimeout time.Duration
+
+	wg          sync.WaitGroup
+	cancel      context.CancelFunc
+	running     atomic.Bool
+	lastPoll    atomic.Int64
+	lastRelease atomic.Int64
+}
+
+func NewQueue(db database.DBTX) *Queue {
+	return &Queue{
+		q:            database.New(db),
+		handlers:     map[string]Handler{},
+		recurring:    map[string]recurring{},
+		PollInterval: time.Second,
+		LockTimeout:  15 * time.Minute,
+	}
+}
+
+// Register sets the handler of a kind of job. It must be called before
+// Start.
+func (q *Queue) Register(kind string, handler Handler) {
+	q.handlers[kind] = handler
+}
+
+// Every registers handler as a recurring job that runs every interval.
+// After each run, successful or not, the next one is scheduled interval
+// later.
+func (q *Queue) Every(kind string, interval time.Duration, handler Handler) {
+	q.Register(kind, handler)
+	q.recurring[kind] = recurring{kind: kind, interval: interval}
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+	uniqueKey   string
+}
+
+type Option func(*enqueueOptions)
+
+// RunAt schedules a job for later instead of running it as soon as possible.
+func RunAt(t time.Time) Option {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+func MaxAttempts(n int) Option {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// UniqueKey skips enqueueing while a pending or running job has the key.
+func UniqueKey(key string) Option {
+	return func(o *enqueueOptions) { o.uniqueKey = key }
+}
+
+// ErrDuplicate is returned by Enqueue when a job with the same unique key
+// is already queued.
+var ErrDuplicate = errors.New("job with the same unique key is already queued")
+
+// Enqueue adds a job with a JSON-encoded payload.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts ...Option) (uuid.UUID, error) {
+	return q.enqueue(ctx, q.q, kind, payload, opts...)
+}
+
+// EnqueueTx enqueues as part of the transaction tx is bound to, so that the
+// job only exists if the transaction commits.
+func (q *Queue) EnqueueTx(ctx context.Context, tx *database.Queries, kind string, payload any, opts ...Option) (uuid.UUID, error) {
+	return q.enqueue(ctx, tx, kind, payload, opts...)
+}
+
+func (q *Queue) enqueue(ctx context.Context, dbq *database.Queries, kind string, payload any, opts ...Option) (uuid.UUID, error) {
+	o := enqueueOptions{runAt: time.Now(), maxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not encode payload: %w", err)
+	}
+	job, err := dbq.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     raw,
+		MaxAttempts: int32(o.maxAttempts),
+		RunAt:       o.runAt,
+		UniqueKey:   sql.NullString{String: o.uniqueKey, Valid: o.uniqueKey != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrDuplicate
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return job.ID, nil
+}
+
+func recurringKey(kind string) string {
+	return "recurring:" + kind
+}
+
+// Start schedules the recurring jobs and starts workers goroutines. Workers
+// stop claiming jobs once ctx is done or Shutdown is called.
+func (q *Queue) Start(ctx context.Context, workers int) error {
+	for _, r := range q.recurring {
+		_, err := q.Enqueue(ctx, r.kind, struct{}{}, UniqueKey(recurringKey(r.kind)))
+		if err != nil && !errors.Is(err, ErrDuplicate) {
+			return fmt.Errorf("could not schedule %s: %w", r.kind, err)
+		}
+	}
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.running.Store(true)
+	for range workers {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+	go func() {
+		q.wg.Wait()
+		q.running.Store(false)
+	}()
+	return nil
+}
+
+// Shutdown stops claiming new jobs and waits for running ones to finish. If
+// ctx is done first, Shutdown returns its error; jobs that were still
+// running are picked up again after LockTimeout.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running reports whether workers are started and not yet shut down.
+func (q *Queue) Running() bool {
+	return q.running.Load()
+}
+
+// LastPoll is when a worker last checked the queue.
+func (q *Queue) LastPoll() time.Time {
+	return time.Unix(0, q.lastPoll.Load())
+}
+
+func (q *Queue) kinds() []string {
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+	kinds := q.kinds()
+	for {
+		q.lastPoll.Store(time.Now().UnixNano())
+		q.releaseStaleJobs(ctx)
+		job, err := q.q.ClaimJob(ctx, kinds)
+		if err == nil {
+			// Let a job that has started finish even if we are shutting
+			// down; Shutdown bounds how long we wait for it.
+			q.run(context.WithoutCancel(ctx), job)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "claiming job failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// releaseStaleJobs requeues jobs of dead workers, at most once a minute
+// across all workers of the queue.
+func (q *Queue) releaseStaleJobs(ctx context.Context) {
+	last := q.lastRelease.Load()
+	now := time.Now()
+	if now.Sub(time.Unix(0, last)) < time.Minute || !q.lastRelease.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if _, err := q.q.ReleaseStaleJobs(ctx, now.Add(-q.LockTimeout)); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "releasing stale jobs failed", "error", err)
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job database.Job) {
+	err := q.call(ctx, job)
+	switch {
+	case err == nil:
+		err = q.q.CompleteJob(ctx, job.ID)
+	case int(job.Attempts) < int(job.MaxAttempts):
+		err = q.q.RetryJob(ctx, database.RetryJobParams{
+			ID:        job.ID,
+			RunAt:     time.Now().Add(Backoff(int(job.Attempts))),
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+	default:
+		slog.ErrorContext(ctx, "job failed for good", "job_id", job.ID, "kind", job.Kind, "error", err)
+		err = q.q.FailJob(ctx, database.FailJobParams{
+			ID:        job.ID,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "recording job result failed", "job_id", job.ID, "error", err)
+	}
+
+	// A recurring job is only rescheduled once it leaves the queue, and the
+	// unique key keeps a retrying run from overlapping the next one.
+	if r, ok := q.recurring[job.Kind]; ok && job.UniqueKey.Valid {
+		_, err := q.Enqueue(ctx, r.kind, struct{}{}, UniqueKey(recurringKey(r.kind)), RunAt(time.Now().Add(r.interval)))
+		if err != nil && !errors.Is(err, ErrDuplicate) {
+			slog.ErrorContext(ctx, "rescheduling job failed", "kind", r.kind, "error", err)
+		}
+	}
+}
+
+func (q *Queue) call(ctx context.Context, job database.Job) (err error) {
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
@@ -0,0 +1,45 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/jobs"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		4:  80 * time.Second,
+		30: time.Hour,
+	}
+	for attempts, expected := range cases {
+		if backoff := jobs.Backoff(attempts); backoff != expected {
+			t.Errorf("expected backoff after %d attempts to be %v, got %v", attempts, expected, backoff)
+		}
+	}
+}
+
+func TestHandleDecodesPayload(t *testing.T) {
+	type payload struct {
+		UserID string `json:"user_id"`
+	}
+	var got payload
+	handler := jobs.Handle(func(ctx context.Context, p payload) error {
+		got = p
+		return nil
+	})
+
+	if err := handler(context.Background(), json.RawMessage(`{"user_id":"abc"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.UserID != "abc" {
+		t.Errorf("expected decoded payload, got %+v", got)
+	}
+	if err := handler(context.Background(), json.RawMessage(`not json`)); err == nil {
+		t.Errorf("expected error for an invalid payload, got none")
+	}
+}
@@ -0,0 +1,147 @@
+// Package metrics exposes chirpy's Prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/response"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+
+	FileserverHits  prometheus.Counter
+	ChirpsCreated   *prometheus.CounterVec
+	WebhookOutcomes *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Database query latency by sqlc query name.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"query"}),
+		FileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests served by the /app/ file server.",
+		}),
+		ChirpsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created, by source (api or import).",
+		}, []string{"source"}),
+		WebhookOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhooks_total",
+			Help: "Webhooks received from Polka (inbound) and delivery attempts to user endpoints (outbound), by outcome.",
+		}, []string{"direction", "outcome"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.queryDuration,
+		m.FileserverHits,
+		m.ChirpsCreated,
+		m.WebhookOutcomes,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (m *Metrics) GaugeFunc(name, help string, fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn))
+}
+
+// Middleware records every request handled by next, which is expected to be
+// a ServeMux: the route label is the pattern the mux matched, so that
+// requests for different chirps are counted together.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := response.Wrap(w)
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := rec.Status()
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		m.requests.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// QueryName extracts the sqlc query name from the "-- name: X :kind"
+// comment sqlc puts at the start of every generated query.
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+type instrumentedDB struct {
+	db database.DBTX
+	m  *Metrics
+}
+
+// InstrumentDB times every query made through db. Pass the result to
+// database.New.
+func (m *Metrics) InstrumentDB(db database.DBTX) database.DBTX {
+	return &instrumentedDB{db: db, m: m}
+}
+
+func (i *instrumentedDB) observe(query string, start time.Time) {
+	i.m.queryDuration.WithLabelValues(QueryName(query)).Observe(time.Since(start).Seconds())
+}
+
+func (i *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer i.observe(query, time.Now())
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer i.observe(query, time.Now())
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer i.observe(query, time.Now())
+	return i.db.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wilgnert/chirpy/internal/metrics"
+)
+
+func TestQueryName(t *testing.T) {
+	cases := map[string]string{
+		"-- name: GetUserByID :one\nselect * from users where id = $1": "GetUserByID",
+		"select 1": "unknown",
+	}
+	for query, expected := range cases {
+		if name := metrics.QueryName(query); name != expected {
+			t.Errorf("expected %q, got %q", expected, name)
+		}
+	}
+}
+
+func TestMiddlewareLabelsRoutePattern(t *testing.T) {
+	m := metrics.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Middleware(mux)
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, expected := range []string{
+		`chirpy_http_requests_total{method="GET",route="GET /api/chirps/{chirpID}",status="404"} 2`,
+		`chirpy_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected metrics to contain %s", expected)
+		}
+	}
+}
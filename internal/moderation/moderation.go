@@ -0,0 +1,282 @@
+// Package moderation checks chirp bodies against lists of banned terms.
+//
+// Terms are matched case-insensitively on a normalized copy of the body, so
+// accented, full-width and leetspeak spellings ("k3rfuffl3", "ｆｏｒｎａｘ")
+// match the plain term. Each term carries an Action deciding what happens to
+// a chirp that contains it.
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+const maskReplacement = "****"
+
+func (a Action) Valid() bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
+
+type Rule struct {
+	Term   string `json:"term"`
+	Action Action `json:"action"`
+}
+
+// Validate reports whether the rule can be loaded: its action must be valid
+// and its term must not normalize to nothing.
+func (r Rule) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+func (r Rule) compile() (*regexp.Regexp, error) {
+	if !r.Action.Valid() {
+		return nil, fmt.Errorf("invalid action %q for term %q", r.Action, r.Term)
+	}
+	return compileTerm(r.Term)
+}
+
+type Match struct {
+	Term   string `json:"term"`
+	Action Action `json:"action"`
+}
+
+type Result struct {
+	Body    string
+	Matches []Match
+}
+
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, m := range r.Matches {
+		if m.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Engine holds a precompiled rule set. Check is safe to call concurrently
+// with Load, which swaps the whole rule set at once.
+type Engine struct {
+	rules atomic.Pointer[[]compiledRule]
+}
+
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	if err := e.Load(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Load compiles rules and replaces the engine's rule set. The current rule
+// set is kept if any rule is invalid.
+func (e *Engine) Load(rules []Rule) error {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := rule.compile()
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, re: re})
+	}
+	e.rules.Store(&compiled)
+	return nil
+}
+
+func (e *Engine) Rules() []Rule {
+	compiled := *e.rules.Load()
+	rules := make([]Rule, 0, len(compiled))
+	for _, rule := range compiled {
+		rules = append(rules, rule.Rule)
+	}
+	return rules
+}
+
+// Check runs body through every rule. Masked terms are replaced in the
+// returned body; rejections and flags are only reported through Matches and
+// it's up to the caller to act on them.
+func (e *Engine) Check(body string) Result {
+	normalized, origStart, origEnd := normalize(body)
+	var matches []Match
+	var masked [][2]int
+	for _, rule := range *e.rules.Load() {
+		matched := false
+		for _, loc := range rule.re.FindAllStringIndex(normalized, -1) {
+			if !isBoundary(normalized, loc[0], loc[1]) {
+				continue
+			}
+			matched = true
+			if rule.Action == ActionMask {
+				masked = append(masked, [2]int{origStart[loc[0]], origEnd[loc[1]-1]})
+			}
+		}
+		if matched {
+			matches = append(matches, Match{Term: rule.Term, Action: rule.Action})
+		}
+	}
+	return Result{Body: mask(body, masked), Matches: matches}
+}
+
+// LoadFile reads rules from a text file with one "term [action]" pair per
+// line. The action defaults to mask; blank lines and lines starting with #
+// are ignored.
+func LoadFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func Parse(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := Rule{Term: fields[0], Action: ActionMask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			rule.Action = Action(fields[1])
+		default:
+			return nil, fmt.Errorf("line %d: expected \"term [action]\"", lineNumber)
+		}
+		if !rule.Action.Valid() {
+			return nil, fmt.Errorf("line %d: invalid action %q", lineNumber, rule.Action)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// leetVariants lists the characters commonly substituted for a letter.
+var leetVariants = map[rune]string{
+	'a': "4@",
+	'b': "8",
+	'e': "3",
+	'g': "9",
+	'i': "1!|",
+	'l': "1|",
+	'o': "0",
+	's': "5$",
+	't': "7+",
+	'z': "2",
+}
+
+func compileTerm(term string) (*regexp.Regexp, error) {
+	normalized, _, _ := normalize(strings.TrimSpace(term))
+	if normalized == "" {
+		return nil, fmt.Errorf("term %q is empty once normalized", term)
+	}
+	var pattern strings.Builder
+	for _, r := range normalized {
+		variants, ok := leetVariants[r]
+		if !ok {
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+			continue
+		}
+		pattern.WriteString("[" + regexp.QuoteMeta(string(r)+variants) + "]")
+	}
+	return regexp.Compile(pattern.String())
+}
+
+// normalize lowercases s and strips diacritics and compatibility forms. It
+// also returns, for every byte of the result, the byte range of the rune of
+// s it came from so that matches can be mapped back onto the original.
+func normalize(s string) (string, []int, []int) {
+	var b strings.Builder
+	var origStart, origEnd []int
+	for i, r := range s {
+		size := utf8.RuneLen(r)
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			for range utf8.RuneLen(d) {
+				origStart = append(origStart, i)
+				origEnd = append(origEnd, i+size)
+			}
+			b.WriteRune(d)
+		}
+	}
+	return b.String(), origStart, origEnd
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isBoundary(s string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(s[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(s) {
+		if r, _ := utf8.DecodeRuneInString(s[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func mask(s string, ranges [][2]int) string {
+	if len(ranges) == 0 {
+		return s
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var b strings.Builder
+	last := 0
+	for _, rng := range ranges {
+		if rng[0] < last {
+			if rng[1] > last {
+				last = rng[1]
+			}
+			continue
+		}
+		b.WriteString(s[last:rng[0]])
+		b.WriteString(maskReplacement)
+		last = rng[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
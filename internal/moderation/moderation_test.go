@@ -0,0 +1,122 @@
+package moderation_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wilgnert/chirpy/internal/moderation"
+)
+
+func newEngine(t *testing.T, rules ...moderation.Rule) *moderation.Engine {
+	t.Helper()
+	e, err := moderation.NewEngine(rules)
+	if err != nil {
+		t.Fatalf("unexpected error creating engine: %v", err)
+	}
+	return e
+}
+
+func TestMaskMatchesWholeWordsOnly(t *testing.T) {
+	e := newEngine(t, moderation.Rule{Term: "fornax", Action: moderation.ActionMask})
+
+	cases := map[string]string{
+		"I hear Mastodon is better than Chirpy. Fornax I need to migrate": "I hear Mastodon is better than Chirpy. **** I need to migrate",
+		"fornax, FORNAX and fornax!":                                      "****, **** and ****!",
+		"fornaxes are fine":                                               "fornaxes are fine",
+	}
+	for body, expected := range cases {
+		if got := e.Check(body).Body; got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestMaskHandlesLeetspeakAndUnicode(t *testing.T) {
+	e := newEngine(t, moderation.Rule{Term: "kerfuffle", Action: moderation.ActionMask})
+
+	for _, body := range []string{"what a k3rfuffl3", "what a kérfüfflé", "what a ｋｅｒｆｕｆｆｌｅ", "what a KERFUFF1E"} {
+		result := e.Check(body)
+		if result.Body != "what a ****" {
+			t.Errorf("expected %q to be masked, got %q", body, result.Body)
+		}
+		if len(result.Matches) != 1 || result.Matches[0].Term != "kerfuffle" {
+			t.Errorf("expected a single kerfuffle match for %q, got %v", body, result.Matches)
+		}
+	}
+}
+
+func TestRejectAndFlagLeaveBodyUntouched(t *testing.T) {
+	e := newEngine(t,
+		moderation.Rule{Term: "sharbert", Action: moderation.ActionReject},
+		moderation.Rule{Term: "fornax", Action: moderation.ActionFlag},
+	)
+
+	result := e.Check("sharbert and fornax")
+	if result.Body != "sharbert and fornax" {
+		t.Errorf("expected body to be untouched, got %q", result.Body)
+	}
+	if !result.Rejected() {
+		t.Errorf("expected body to be rejected")
+	}
+	if !result.Flagged() {
+		t.Errorf("expected body to be flagged")
+	}
+}
+
+func TestLoadReplacesRules(t *testing.T) {
+	e := newEngine(t, moderation.Rule{Term: "fornax", Action: moderation.ActionMask})
+
+	if err := e.Load([]moderation.Rule{{Term: "sharbert", Action: moderation.ActionMask}}); err != nil {
+		t.Fatalf("unexpected error reloading rules: %v", err)
+	}
+	if got := e.Check("fornax sharbert").Body; got != "fornax ****" {
+		t.Errorf("expected only the reloaded rule to apply, got %q", got)
+	}
+
+	if err := e.Load([]moderation.Rule{{Term: "fornax", Action: "ban"}}); err == nil {
+		t.Errorf("expected error loading an invalid action, got none")
+	}
+	if got := e.Check("fornax sharbert").Body; got != "fornax ****" {
+		t.Errorf("expected a failed reload to keep the previous rules, got %q", got)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := moderation.Rule{Term: "fornax", Action: moderation.ActionReject}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, rule := range []moderation.Rule{
+		{Term: "fornax", Action: "ban"},
+		{Term: "   ", Action: moderation.ActionMask},
+		{Term: "\u0301\u0308", Action: moderation.ActionMask},
+	} {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", rule)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	rules, err := moderation.Parse(strings.NewReader("# banned terms\nkerfuffle\n\nsharbert reject\nfornax flag\n"))
+	if err != nil {
+		t.Fatalf("unexpected error parsing rules: %v", err)
+	}
+	expected := []moderation.Rule{
+		{Term: "kerfuffle", Action: moderation.ActionMask},
+		{Term: "sharbert", Action: moderation.ActionReject},
+		{Term: "fornax", Action: moderation.ActionFlag},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %d", len(expected), len(rules))
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Errorf("expected rule %v, got %v", expected[i], rules[i])
+		}
+	}
+
+	if _, err := moderation.Parse(strings.NewReader("fornax ban\n")); err == nil {
+		t.Errorf("expected error parsing an invalid action, got none")
+	}
+}
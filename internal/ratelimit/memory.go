@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	refills time.Time
+}
+
+// MemoryLimiter keeps buckets in process memory. Each instance of the
+// server enforces its own limits, so use PostgresLimiter when running more
+// than one.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	calls   int
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), last: now}
+		m.buckets[key] = b
+	}
+	var res Result
+	b.tokens, res = take(b.tokens, b.last, now, limit)
+	b.last = now
+	b.refills = now.Add(res.Reset)
+
+	m.calls++
+	if m.calls%1024 == 0 {
+		m.sweep(now)
+	}
+	return res, nil
+}
+
+// sweep forgets buckets that have refilled completely, since a fresh bucket
+// behaves the same way.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.refills) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterSpendsAndRefills(t *testing.T) {
+	now := time.Now()
+	m := NewMemoryLimiter()
+	m.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Per: time.Minute}
+
+	for i := range 2 {
+		res, err := m.Allow(context.Background(), "ip:1", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	res, _ := m.Allow(context.Background(), "ip:1", limit)
+	if res.Allowed {
+		t.Fatalf("expected request over the limit to be rejected")
+	}
+	if res.Remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", res.Remaining)
+	}
+	if res.RetryAfter != 30*time.Second {
+		t.Errorf("expected to retry after 30s, got %v", res.RetryAfter)
+	}
+
+	now = now.Add(30 * time.Second)
+	if res, _ := m.Allow(context.Background(), "ip:1", limit); !res.Allowed {
+		t.Errorf("expected request to be allowed after the bucket refilled")
+	}
+}
+
+func TestMemoryLimiterKeysAreIndependent(t *testing.T) {
+	m := NewMemoryLimiter()
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	m.Allow(context.Background(), "ip:1", limit)
+	if res, _ := m.Allow(context.Background(), "ip:2", limit); !res.Allowed {
+		t.Errorf("expected a different key to have its own bucket")
+	}
+}
+
+func TestLimitScale(t *testing.T) {
+	limit := Limit{Requests: 10, Per: time.Minute}
+	if scaled := limit.Scale(2.5); scaled.Requests != 25 {
+		t.Errorf("expected 25 requests, got %d", scaled.Requests)
+	}
+	if scaled := limit.Scale(0); scaled.Requests != 1 {
+		t.Errorf("expected scaling to keep at least 1 request, got %d", scaled.Requests)
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+// PostgresLimiter keeps buckets in the rate_limit_buckets table so that
+// every instance of the server shares them. Each Allow locks the bucket row
+// for the duration of a short transaction.
+type PostgresLimiter struct {
+	db *sql.DB
+}
+
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{db: db}
+}
+
+func (p *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now().UTC()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	if err := q.EnsureRateLimitBucket(ctx, database.EnsureRateLimitBucketParams{
+		Key:       key,
+		Tokens:    float64(limit.Requests),
+		UpdatedAt: now,
+	}); err != nil {
+		return Result{}, err
+	}
+	b, err := q.GetRateLimitBucketForUpdate(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	tokens, res := take(b.Tokens, b.UpdatedAt, now, limit)
+	if err := q.UpdateRateLimitBucket(ctx, database.UpdateRateLimitBucketParams{
+		Key:       key,
+		Tokens:    tokens,
+		UpdatedAt: now,
+	}); err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+// Prune deletes buckets that haven't been used for maxIdle. It should be
+// called periodically with maxIdle at least as long as the longest Limit.Per
+// in use, so that only buckets that have refilled completely are dropped.
+func (p *PostgresLimiter) Prune(ctx context.Context, maxIdle time.Duration) (int64, error) {
+	return database.New(p.db).DeleteIdleRateLimitBuckets(ctx, time.Now().UTC().Add(-maxIdle))
+}
@@ -0,0 +1,65 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Requests requests per Per. Buckets start full, so a client
+// can spend the whole budget at once and then refills at Requests/Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) Scale(factor float64) Limit {
+	return Limit{Requests: int(math.Max(1, math.Round(float64(l.Requests)*factor))), Per: l.Per}
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed. It
+	// is zero when Allowed is true.
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	// Allow takes a token from the bucket identified by key.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// take refills a bucket holding tokens as of last up to now and tries to
+// take a token from it. It returns the tokens left in the bucket.
+func take(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
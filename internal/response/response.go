@@ -0,0 +1,58 @@
+// Package response records what handlers write, so that the tracing,
+// metrics and access log middlewares can share a single wrapper around the
+// ResponseWriter instead of each adding their own.
+package response
+
+import "net/http"
+
+// Recorder is an http.ResponseWriter that remembers the status code and the
+// number of body bytes written through it.
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Wrap returns w if it already is a Recorder, which is the case when an
+// outer middleware wrapped it, and a new Recorder around w otherwise.
+func Wrap(w http.ResponseWriter) *Recorder {
+	if rec, ok := w.(*Recorder); ok {
+		return rec
+	}
+	return &Recorder{ResponseWriter: w}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status is the status code sent, which is 200 when the handler wrote
+// nothing at all, as net/http sends then.
+func (r *Recorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Bytes is the size of the response body written so far.
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
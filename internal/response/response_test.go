@@ -0,0 +1,29 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wilgnert/chirpy/internal/response"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := response.Wrap(httptest.NewRecorder())
+	if rec.Status() != http.StatusOK || rec.Bytes() != 0 {
+		t.Errorf("expected an untouched response to count as an empty 200, got %d and %d bytes", rec.Status(), rec.Bytes())
+	}
+	rec.WriteHeader(http.StatusTeapot)
+	rec.WriteHeader(http.StatusInternalServerError)
+	rec.Write([]byte("short and stout"))
+	if rec.Status() != http.StatusTeapot || rec.Bytes() != 15 {
+		t.Errorf("expected the first status and 15 bytes, got %d and %d", rec.Status(), rec.Bytes())
+	}
+}
+
+func TestWrapReusesRecorder(t *testing.T) {
+	outer := response.Wrap(httptest.NewRecorder())
+	if inner := response.Wrap(outer); inner != outer {
+		t.Error("expected wrapping a Recorder to return it")
+	}
+}
@@ -0,0 +1,172 @@
+// Package spam detects chirps that repeat a user's recent chirps or look
+// like link spam.
+package spam
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Action is what happens to a chirp detected as spam.
+type Action string
+
+const (
+	// ActionReject refuses to create the chirp.
+	ActionReject Action = "reject"
+	// ActionReview creates the chirp and flags it for moderators to review;
+	// it stays visible unless they hide it.
+	ActionReview Action = "review"
+	// ActionThrottle creates the chirp hidden without telling its author.
+	ActionThrottle Action = "throttle"
+)
+
+func (a Action) Valid() bool {
+	return a == ActionReject || a == ActionReview || a == ActionThrottle
+}
+
+type Config struct {
+	Action Action
+	// DuplicateWindow is how far back chirps are compared against.
+	DuplicateWindow time.Duration
+	// DuplicateSimilarity is the word-level Jaccard similarity from which
+	// two chirps count as near-duplicates.
+	DuplicateSimilarity float64
+	// MaxDuplicates is how many copies of the same chirp may be posted
+	// within the window; the next near-duplicate is spam.
+	MaxDuplicates int
+	MaxURLs       int
+	// BlockedDomains match the domain itself and all of its subdomains.
+	BlockedDomains []string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Action:              ActionReview,
+		DuplicateWindow:     10 * time.Minute,
+		DuplicateSimilarity: 0.8,
+		MaxDuplicates:       2,
+		MaxURLs:             3,
+	}
+}
+
+type Verdict struct {
+	Spam    bool     `json:"spam"`
+	Reasons []string `json:"reasons"`
+}
+
+type Detector struct {
+	Config
+}
+
+func NewDetector(cfg Config) (*Detector, error) {
+	if !cfg.Action.Valid() {
+		return nil, fmt.Errorf("invalid spam action %q", cfg.Action)
+	}
+	for i, domain := range cfg.BlockedDomains {
+		cfg.BlockedDomains[i] = strings.ToLower(strings.TrimPrefix(domain, "."))
+	}
+	return &Detector{Config: cfg}, nil
+}
+
+// Check inspects body given the bodies of the chirps its author posted
+// within DuplicateWindow.
+func (d *Detector) Check(body string, recent []string) Verdict {
+	var v Verdict
+
+	words := wordSet(body)
+	duplicates := 0
+	for _, other := range recent {
+		if similarity(words, wordSet(other)) >= d.DuplicateSimilarity {
+			duplicates++
+		}
+	}
+	if d.MaxDuplicates > 0 && duplicates >= d.MaxDuplicates {
+		v.Reasons = append(v.Reasons, fmt.Sprintf("%d near-duplicates in the last %s", duplicates, d.DuplicateWindow))
+	}
+
+	urls := urlPattern.FindAllString(body, -1)
+	if d.MaxURLs > 0 && len(urls) > d.MaxURLs {
+		v.Reasons = append(v.Reasons, fmt.Sprintf("%d links", len(urls)))
+	}
+	for _, raw := range urls {
+		if domain := d.blockedDomain(raw); domain != "" {
+			v.Reasons = append(v.Reasons, fmt.Sprintf("links to blocked domain %s", domain))
+		}
+	}
+
+	v.Spam = len(v.Reasons) > 0
+	return v
+}
+
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+func (d *Detector) blockedDomain(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range d.BlockedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+func wordSet(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// LoadDomains reads a blocked domain list with one domain per line. Blank
+// lines and lines starting with # are ignored.
+func LoadDomains(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDomains(f)
+}
+
+func ParseDomains(r io.Reader) ([]string, error) {
+	var domains []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains, scanner.Err()
+}
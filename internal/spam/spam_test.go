@@ -0,0 +1,78 @@
+package spam_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wilgnert/chirpy/internal/spam"
+)
+
+func newDetector(t *testing.T, cfg spam.Config) *spam.Detector {
+	t.Helper()
+	d, err := spam.NewDetector(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error creating detector: %v", err)
+	}
+	return d
+}
+
+func TestNearDuplicates(t *testing.T) {
+	d := newDetector(t, spam.DefaultConfig())
+	recent := []string{
+		"Buy my course now, limited offer!",
+		"buy my course NOW limited offer",
+	}
+
+	if v := d.Check("Buy my course now... limited offer!!", recent[:1]); v.Spam {
+		t.Errorf("expected a single repeat to be allowed, got %v", v.Reasons)
+	}
+	if v := d.Check("Buy my course now... limited offer!!", recent); !v.Spam {
+		t.Errorf("expected a third near-duplicate to be spam")
+	}
+	if v := d.Check("I had a great time at the beach today", recent); v.Spam {
+		t.Errorf("expected an unrelated chirp not to be spam, got %v", v.Reasons)
+	}
+}
+
+func TestTooManyURLs(t *testing.T) {
+	d := newDetector(t, spam.DefaultConfig())
+
+	if v := d.Check("see https://a.example and www.b.example", nil); v.Spam {
+		t.Errorf("expected two links to be allowed, got %v", v.Reasons)
+	}
+	if v := d.Check("https://a.example https://b.example https://c.example https://d.example", nil); !v.Spam {
+		t.Errorf("expected four links to be spam")
+	}
+}
+
+func TestBlockedDomains(t *testing.T) {
+	cfg := spam.DefaultConfig()
+	cfg.BlockedDomains = []string{"Spam.example"}
+	d := newDetector(t, cfg)
+
+	v := d.Check("free stuff at https://deals.spam.example/win", nil)
+	if !v.Spam || len(v.Reasons) != 1 || !strings.Contains(v.Reasons[0], "spam.example") {
+		t.Errorf("expected subdomain of a blocked domain to be spam, got %v", v)
+	}
+	if v := d.Check("read https://notspam.example", nil); v.Spam {
+		t.Errorf("expected a domain merely ending with a blocked name not to be spam, got %v", v.Reasons)
+	}
+}
+
+func TestParseDomains(t *testing.T) {
+	domains, err := spam.ParseDomains(strings.NewReader("# known spam\nspam.example\n\nscam.example\n"))
+	if err != nil {
+		t.Fatalf("unexpected error parsing domains: %v", err)
+	}
+	if len(domains) != 2 || domains[0] != "spam.example" || domains[1] != "scam.example" {
+		t.Errorf("unexpected domains %v", domains)
+	}
+}
+
+func TestInvalidAction(t *testing.T) {
+	cfg := spam.DefaultConfig()
+	cfg.Action = "ignore"
+	if _, err := spam.NewDetector(cfg); err == nil {
+		t.Errorf("expected error creating detector with invalid action, got none")
+	}
+}
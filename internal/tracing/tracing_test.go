@@ -0,0 +1,109 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wilgnert/chirpy/internal/tracing"
+)
+
+func setup(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.ExporterNone); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	recorder := setup(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tracing.Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/chirps/{chirpID}" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if span.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected span to continue the caller's trace, got %s", span.Parent().TraceID())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected a 500 to mark the span as failed")
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	setup(t)
+	ctx, span := tracing.Tracer().Start(context.Background(), "emit")
+	raw := tracing.Inject(ctx)
+	span.End()
+
+	extracted := trace.SpanContextFromContext(tracing.Extract(context.Background(), raw))
+	if extracted.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("expected trace %s, got %s", span.SpanContext().TraceID(), extracted.TraceID())
+	}
+
+	if sc := trace.SpanContextFromContext(tracing.Extract(context.Background(), []byte("not json"))); sc.IsValid() {
+		t.Errorf("expected invalid input to be ignored")
+	}
+}
+
+type fakeDB struct {
+	err error
+}
+
+func (db fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, db.err
+}
+
+func (db fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, db.err
+}
+
+func (db fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, db.err
+}
+
+func (db fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestTraceDB(t *testing.T) {
+	recorder := setup(t)
+	db := tracing.TraceDB(fakeDB{err: errors.New("connection refused")})
+	db.ExecContext(context.Background(), "-- name: DeleteChirp :exec\ndelete from chirps where id = $1")
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "DeleteChirp" {
+		t.Errorf("unexpected span name %q", spans[0].Name())
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected the failed query to mark the span as failed")
+	}
+}
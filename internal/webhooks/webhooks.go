@@ -0,0 +1,201 @@
+// Package webhooks sends signed event payloads to endpoints registered by
+// users. Deliveries are queued in the database by the caller; this package
+// only knows how to send one and how long to wait before retrying it.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/auth"
+)
+
+const (
+	EventChirpCreated  = "chirp.created"
+	EventChirpDeleted  = "chirp.deleted"
+	EventUserMentioned = "user.mentioned"
+	EventUserFollowed  = "user.followed"
+	// EventChirpyRedExpired is sent when a subscription runs out without
+	// being renewed.
+	EventChirpyRedExpired = "chirpy_red.expired"
+	// EventTest is only sent by the "send test event" endpoint.
+	EventTest = "webhook.test"
+)
+
+// Events are the events endpoints can subscribe to.
+var Events = []string{EventChirpCreated, EventChirpDeleted, EventUserMentioned, EventUserFollowed, EventChirpyRedExpired}
+
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	TimestampHeader = "Chirpy-Timestamp"
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+)
+
+// MaxAttempts is how many times a delivery is tried before it is
+// dead-lettered.
+const MaxAttempts = 8
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Backoff is how long to wait after the given number of failed attempts:
+// 30s, 1m, 2m, 4m and so on, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("could not generate random bytes: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b[:]), nil
+}
+
+type Delivery struct {
+	ID      string
+	Event   string
+	URL     string
+	Secret  string
+	Payload []byte
+}
+
+// Result is what is known about a delivery attempt. The response body is
+// deliberately not kept: showing it to the endpoint's owner would let them
+// read whatever the URL points at.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// maxDrainedBody is how much of a response is read so that the connection
+// can be reused.
+const maxDrainedBody = 4 << 10
+
+// ErrBlockedAddress is returned for endpoints that resolve to an address
+// webhooks may not be sent to.
+var ErrBlockedAddress = errors.New("webhook URL resolves to a blocked address")
+
+// sharedAddressSpace is carrier-grade NAT space, which netip doesn't count
+// as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Blocked reports whether addr is loopback, private, link-local (which
+// includes the 169.254.169.254 cloud metadata service), multicast or
+// unspecified, i.e. anything that isn't a public unicast address.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr) ||
+		(addr.Is4() && addr.As4()[0] == 0)
+}
+
+// CheckHost resolves host and fails if any of its addresses is blocked, so
+// that bad URLs are rejected when they are registered. NewClient checks
+// again when connecting, since DNS can change in between.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if Blocked(addr) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client deliveries are sent with. Unless
+// allowPrivate is set, which is meant for local development, it refuses to
+// connect to blocked addresses. The check happens on the resolved address
+// of every connection, so DNS rebinding can't get around it. Redirects are
+// never followed.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || Blocked(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Send posts the payload signed with the endpoint secret the same way Polka
+// signs its webhooks to us: an HMAC-SHA256 of "<timestamp>.<payload>". Any
+// 2xx response is a success; everything else is returned as an error along
+// with what is known about the response.
+func Send(ctx context.Context, client *http.Client, d Delivery) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{}, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, "v1="+auth.SignWebhook(d.Payload, now, d.Secret))
+
+	res, err := client.Do(req)
+	result := Result{Duration: time.Since(now)}
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxDrainedBody))
+	result.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return result, fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+	return result, nil
+}
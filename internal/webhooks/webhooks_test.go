@@ -0,0 +1,126 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/webhooks"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		20: 6 * time.Hour,
+	}
+	for attempts, expected := range cases {
+		if backoff := webhooks.Backoff(attempts); backoff != expected {
+			t.Errorf("expected backoff after %d attempts to be %v, got %v", attempts, expected, backoff)
+		}
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	payload := []byte(`{"type":"chirp.created"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		headers := http.Header{}
+		headers.Set(auth.WebhookTimestampHeader, r.Header.Get(webhooks.TimestampHeader))
+		headers.Set(auth.WebhookSignatureHeader, r.Header.Get(webhooks.SignatureHeader))
+		if err := auth.VerifyWebhookSignature(headers, body, []string{"endpoint-secret"}, time.Minute, time.Now()); err != nil {
+			t.Errorf("expected a valid signature, got %v", err)
+		}
+		if r.Header.Get(webhooks.EventHeader) != "chirp.created" {
+			t.Errorf("expected event header, got %q", r.Header.Get(webhooks.EventHeader))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	res, err := webhooks.Send(context.Background(), server.Client(), webhooks.Delivery{
+		ID:      "delivery-id",
+		Event:   "chirp.created",
+		URL:     server.URL,
+		Secret:  "endpoint-secret",
+		Payload: payload,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error sending webhook: %v", err)
+	}
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", res.StatusCode)
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	res, err := webhooks.Send(context.Background(), server.Client(), webhooks.Delivery{URL: server.URL, Payload: []byte(`{}`)})
+	if err == nil {
+		t.Fatalf("expected error for a 500 response, got none")
+	}
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the status to be kept for the delivery log, got %+v", res)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fe80::1":         true,
+		"fd00:ec2::254":   true,
+		"::ffff:10.0.0.1": true,
+		"93.184.216.34":   false,
+		"2606:4700::1111": false,
+	}
+	for raw, expected := range cases {
+		if blocked := webhooks.Blocked(netip.MustParseAddr(raw)); blocked != expected {
+			t.Errorf("expected Blocked(%s) to be %v", raw, expected)
+		}
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the request not to reach a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := webhooks.Send(context.Background(), webhooks.NewClient(time.Second, false), webhooks.Delivery{URL: server.URL, Payload: []byte(`{}`)})
+	if !errors.Is(err, webhooks.ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestNewClientDoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Error("expected the redirect not to be followed")
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	res, err := webhooks.Send(context.Background(), webhooks.NewClient(time.Second, true), webhooks.Delivery{URL: server.URL, Payload: []byte(`{}`)})
+	if err == nil || res.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect to be reported as a failure, got %+v and %v", res, err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/jobs"
+	"github.com/wilgnert/chirpy/internal/ratelimit"
+	"github.com/wilgnert/chirpy/internal/webhooks"
+)
+
+const (
+	jobGenerateDataExport   = "generate_data_export"
+	jobPurgeDeletedAccounts = "purge_deleted_accounts"
+	jobPurgeRefreshTokens   = "purge_refresh_tokens"
+	jobPruneRateLimits      = "prune_rate_limits"
+	jobPruneJobs            = "prune_jobs"
+	jobExpireChirpyRed      = "expire_chirpy_red"
+)
+
+const (
+	// refreshTokenRetention is how long expired and revoked refresh tokens
+	// are kept before being purged.
+	refreshTokenRetention = 7 * 24 * time.Hour
+	finishedJobRetention  = 7 * 24 * time.Hour
+)
+
+func (cfg *apiConfig) registerJobs() {
+	cfg.jobs.Register(jobGenerateDataExport, jobs.Handle(cfg.generateDataExport))
+	cfg.jobs.Every(jobPurgeDeletedAccounts, time.Hour, jobs.Handle(cfg.purgeDeletedAccounts))
+	cfg.jobs.Every(jobPurgeRefreshTokens, time.Hour, jobs.Handle(cfg.purgeRefreshTokens))
+	// A run left queued by an earlier Postgres-backed process still finds
+	// its handler, which does nothing then.
+	if _, ok := cfg.rateLimiter.(*ratelimit.PostgresLimiter); ok {
+		cfg.jobs.Every(jobPruneRateLimits, time.Hour, jobs.Handle(cfg.pruneRateLimits))
+	} else {
+		cfg.jobs.Register(jobPruneRateLimits, jobs.Handle(cfg.pruneRateLimits))
+	}
+	cfg.jobs.Every(jobPruneJobs, 24*time.Hour, jobs.Handle(cfg.pruneJobs))
+	cfg.jobs.Every(jobExpireChirpyRed, 15*time.Minute, jobs.Handle(cfg.expireChirpyRed))
+}
+
+func (cfg *apiConfig) purgeRefreshTokens(ctx context.Context, _ struct{}) error {
+	purged, err := cfg.dbQueries.DeleteStaleRefreshTokens(ctx, time.Now().Add(-refreshTokenRetention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		loggerFrom(ctx).Info("purged stale refresh tokens", "count", purged)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) pruneJobs(ctx context.Context, _ struct{}) error {
+	_, err := cfg.dbQueries.DeleteFinishedJobs(ctx, time.Now().Add(-finishedJobRetention))
+	return err
+}
+
+// expireChirpyRed records the expiry of subscriptions that ran out and
+// sends a chirpy_red.expired event for each of them.
+func (cfg *apiConfig) expireChirpyRed(ctx context.Context, _ struct{}) error {
+	lapsed, err := cfg.dbQueries.ListLapsedChirpyRedUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range lapsed {
+		err := cfg.expireSubscription(ctx, user.ID)
+		if errors.Is(err, billing.ErrInvalidTransition) {
+			// Renewed since it was listed.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not expire subscription of %s: %w", user.ID, err)
+		}
+		cfg.emitEvent(ctx, user.ID, webhooks.EventChirpyRedExpired, map[string]any{
+			"user_id":    user.ID.String(),
+			"expired_at": user.ChirpyRedExpiresAt.Time,
+		})
+	}
+	return nil
+}
+
+func (cfg *apiConfig) expireSubscription(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = cfg.applySubscriptionEvent(ctx, cfg.withTx(tx), uuid.NullUUID{}, userID, billing.EventExpired)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/response"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// newLogger builds the JSON logger used everywhere. levelName is debug,
+// info, warn or error.
+func newLogger(levelName string) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
+type loggerKey struct{}
+
+// loggerFrom returns the logger of the request ctx belongs to, which tags
+// every line with the request ID, or the default logger outside of a
+// request. Lines logged inside a span also carry its trace ID.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	return logger
+}
+
+// validRequestID accepts IDs set by a proxy or client as long as they are
+// short and can't be used to forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return r < 0x21 || r > 0x7e
+	})
+}
+
+// middlewareRequestID gives every request an ID, reusing X-Request-ID when
+// the client sent a usable one, echoes it back and adds it to the request's
+// logger.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		logger := slog.Default().With("request_id", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+	})
+}
+
+// middlewareAccessLog logs one line per request. It must run inside
+// middlewareRequestID and tracing.Middleware, and must not replace the
+// request it passes to the mux, so that the request ID, the trace ID and the
+// matched route pattern are all known.
+func (cfg *apiConfig) middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := response.Wrap(w)
+		next.ServeHTTP(rec, r)
+
+		status := rec.Status()
+		attrs := []any{
+			"method", r.Method,
+			"route", r.Pattern,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rec.Bytes(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if userID := cfg.viewerID(r); userID.Valid {
+			attrs = append(attrs, "user_id", userID.UUID.String())
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		loggerFrom(r.Context()).Log(r.Context(), level, "request", attrs...)
+	})
+}
@@ -1,45 +1,76 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"log/slog"
+	"os"
 
+	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/wilgnert/chirpy/internal/config"
 )
 
 func main() {
-	api := apiConfig{}
-	api.init()
-
-	fileserverHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
-	respondOkHandler := http.HandlerFunc(RespondOK)
-
-	mux := http.NewServeMux()
-	mux.Handle("/app/", api.middlewareMetricsInc(fileserverHandler))
-	mux.HandleFunc("GET /admin/healthz", HandleHealth)
-	mux.HandleFunc("GET /admin/metrics", api.showMetrics)
-	mux.Handle("POST /admin/reset", api.resetMetricsMiddleware(respondOkHandler))
-	// mux.Handle("POST /api/validate_chirp", badWordsReplacementMiddleware(http.HandlerFunc(chripyValidator)))
-
-	mux.Handle("POST /api/users", http.HandlerFunc(api.createUser))
-	mux.Handle("PUT /api/users", http.HandlerFunc(api.updateUserEmailAndPassword))
-
-
-	mux.Handle("POST /api/login", http.HandlerFunc(api.login))
-	mux.Handle("POST /api/refresh", http.HandlerFunc(api.refresh))
-	mux.Handle("POST /api/revoke", http.HandlerFunc(api.revoke))
-
-	mux.Handle("GET /api/chirps", http.HandlerFunc(api.getAllChirps))
-	mux.Handle("GET /api/chirps/{chirpID}", http.HandlerFunc(api.getChirpByID))
-	mux.Handle("DELETE /api/chirps/{chirpID}", http.HandlerFunc(api.deleteChirpByID))
-	mux.Handle("POST /api/chirps", badWordsReplacementMiddleware(chripyValidatorMiddleware(http.HandlerFunc(api.createChirp))))
+	godotenv.Load()
+	c, args, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, commandUsage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := runCommand(c, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	mux.Handle("POST /api/polka/webhooks", http.HandlerFunc(api.handleWebhook))
+// serve runs the API server until it is asked to stop.
+func serve(c config.Config) error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	slog.SetDefault(newLogger(c.LogLevel))
+	if c.AutoMigrate {
+		err := withDatabase(c, func(db *sql.DB) error {
+			return autoMigrate(context.Background(), db)
+		})
+		if err != nil {
+			return fmt.Errorf("could not migrate the database: %w", err)
+		}
+	}
+	api := apiConfig{}
+	if err := api.init(c); err != nil {
+		return fmt.Errorf("could not configure chirpy: %w", err)
+	}
+	api.startWorkers()
 
-	server := http.Server{}
-	server.Handler = mux
-	server.Addr = ":8080"
-	fmt.Printf("Starting server on http://localhost%v/\n", server.Addr)
-	server.ListenAndServe()
+	err := api.serve(api.handler())
+	ctx, cancel := context.WithTimeout(context.Background(), api.server.ShutdownTimeout)
+	defer cancel()
+	api.shutdown(ctx)
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+	}
+	return err
+}
 
+// withDatabase runs fn with its own connection, for migrations that must
+// happen before the server can be set up.
+func withDatabase(c config.Config, fn func(*sql.DB) error) error {
+	if c.DatabaseURL == "" {
+		return errors.New("DB_URL is required")
+	}
+	db, err := sql.Open("postgres", c.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
 }
@@ -2,15 +2,31 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
-	"regexp"
-	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/entitlements"
 )
 
-func chripyValidatorMiddleware(next http.Handler) http.Handler {
+// validateChirpBody is shared by chripyValidatorMiddleware, chirp editing
+// and the chirp importer. maxLength comes from the author's perks.
+func validateChirpBody(body string, maxLength int) error {
+	if len(body) > maxLength {
+		return errors.New("Chirp is too long")
+	}
+	return nil
+}
+
+// chripyValidatorMiddleware checks the body against the chirp length of the
+// caller's tier. Unauthenticated requests get the free limit; createChirp
+// rejects them anyway.
+func (cfg *apiConfig) chripyValidatorMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		type parameters struct {
 			Body   string `json:"body"`
@@ -20,20 +36,26 @@ func chripyValidatorMiddleware(next http.Handler) http.Handler {
 		decoder := json.NewDecoder(r.Body)
 		if err := decoder.Decode(&p); err != nil {
 			if err := respondWithError(w, 500, "Something went wrong"); err != nil {
-				fmt.Println("Could not respond to request")
+				loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 			}
 			return
 		}
-		if len(p.Body) > 140 {
-			if err := respondWithError(w, 400, "Chirp is too long"); err != nil {
-				fmt.Println("Could not respond to request")
+		maxLength := cfg.entitlements.For(entitlements.TierFree).MaxChirpLength
+		if viewerID := cfg.viewerID(r); viewerID.Valid {
+			if perks, err := cfg.perksForUserID(r.Context(), viewerID.UUID); err == nil {
+				maxLength = perks.MaxChirpLength
+			}
+		}
+		if err := validateChirpBody(p.Body, maxLength); err != nil {
+			if err := respondWithError(w, 400, err.Error()); err != nil {
+				loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 			}
 			return
 		}
 		modifiedBodyBytes, err := json.Marshal(p)
 		if err != nil {
+			loggerFrom(r.Context()).Error("could not encode chirp", "error", err)
 			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
-			fmt.Printf("Error encoding JSON: %v", err)
 			return
 		}
 		r.Body = io.NopCloser(bytes.NewBuffer(modifiedBodyBytes))
@@ -42,7 +64,11 @@ func chripyValidatorMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func badWordsReplacementMiddleware(next http.Handler) http.Handler {
+// moderationMiddleware runs the chirp body through the moderation engine.
+// Masked terms are replaced in the body passed on to next, rejected chirps
+// never reach it, and flag matches are left in the request context for
+// createChirp to record once the chirp exists.
+func (cfg *apiConfig) moderationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		type parameters struct {
 			Body   string `json:"body"`
@@ -52,36 +78,61 @@ func badWordsReplacementMiddleware(next http.Handler) http.Handler {
 		decoder := json.NewDecoder(r.Body)
 		if err := decoder.Decode(&p); err != nil {
 			if err := respondWithError(w, 500, "Something went wrong"); err != nil {
-				fmt.Println("Could not respond to request")
+				loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 			}
 			return
 		}
-		badWords := []string{"kerfuffle", "sharbert", "fornax"}
-		for _, badWord := range badWords {
-			re := regexp.MustCompile(fmt.Sprintf(`(?i)\b%s\b`, regexp.QuoteMeta(badWord))) // QuoteMeta escapes special regex chars
-			replacement := strings.Repeat("*", 4)
-			p.Body = re.ReplaceAllString(p.Body, replacement)
+		result := cfg.moderation.Check(p.Body)
+		if result.Rejected() {
+			respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited language")
+			return
 		}
+		p.Body = result.Body
 		modifiedBodyBytes, err := json.Marshal(p)
 		if err != nil {
+			loggerFrom(r.Context()).Error("could not encode chirp", "error", err)
 			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
-			fmt.Printf("Error encoding JSON: %v", err)
 			return
 		}
 		r.Body = io.NopCloser(bytes.NewBuffer(modifiedBodyBytes))
 		r.ContentLength = int64(len(modifiedBodyBytes))
 
+		if result.Flagged() {
+			r = r.WithContext(context.WithValue(r.Context(), moderationMatchesKey, result.Matches))
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// middlewareMetricsAuth lets scrapers in with METRICS_TOKEN as a bearer
+// token, and otherwise requires the access token of a user allowed to view
+// metrics. Only in dev, and only without METRICS_TOKEN, is the endpoint
+// open.
+func (cfg *apiConfig) middlewareMetricsAuth(next http.Handler) http.Handler {
+	requirePermission := cfg.middlewareRequirePermission(auth.PermissionViewMetrics, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.metricsToken == "" && cfg.plataform == "dev" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.metricsToken != "" {
+			token, err := auth.GetBearerToken(r.Header)
+			if err == nil && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.metricsToken)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		requirePermission.ServeHTTP(w, r)
+	})
+}
+
 func (cfg *apiConfig) resetMetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if cfg.plataform != "dev" {
@@ -89,16 +140,91 @@ func (cfg *apiConfig) resetMetricsMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		if err := cfg.dbQueries.DeleteAllRefreshTokens(r.Context()); err != nil {
-			fmt.Println("error deleting all refresh tokens", err.Error())
+			loggerFrom(r.Context()).Error("deleting all refresh tokens failed", "error", err)
 		}
 
 		if err := cfg.dbQueries.DeleteAllChirps(r.Context()); err != nil {
-			fmt.Println("error deleting all chirps", err.Error())
+			loggerFrom(r.Context()).Error("deleting all chirps failed", "error", err)
 		}
 		if err := cfg.dbQueries.DeleteAllUsers(r.Context()); err != nil {
-			fmt.Println("error deleting all users", err.Error())
+			loggerFrom(r.Context()).Error("deleting all users failed", "error", err)
 		}
-		cfg.fileserverHits.Store(0)
 		next.ServeHTTP(w, r)
 	})
 }
+
+
+type contextKey int
+
+const (
+	moderationMatchesKey contextKey = iota
+	authenticatedUserKey
+)
+
+type authenticatedUser struct {
+	ID   uuid.UUID
+	Role auth.Role
+}
+
+func authenticatedUserFromContext(ctx context.Context) (authenticatedUser, bool) {
+	user, ok := ctx.Value(authenticatedUserKey).(authenticatedUser)
+	return user, ok
+}
+
+// validateAccessToken validates a bearer access token and returns the user
+// it was issued to. Deleting an account only revokes its refresh tokens, so
+// the account is checked too: access tokens of deleted accounts would
+// otherwise keep working until they expire.
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	active, err := cfg.dbQueries.IsActiveUser(ctx, userID)
+	if err != nil {
+		loggerFrom(ctx).Error("could not look up user", "error", err)
+		return uuid.UUID{}, err
+	}
+	if !active {
+		return uuid.UUID{}, errors.New("account does not exist or is scheduled for deletion")
+	}
+	return userID, nil
+}
+
+// middlewareRequirePermission only lets requests through from users whose
+// role is granted permission. The role is read from the database rather
+// than trusted from the JWT, so that a demoted admin loses access at once
+// instead of when their token expires. The caller is left in the request
+// context.
+func (cfg *apiConfig) middlewareRequirePermission(permission auth.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearerToken, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		userID, _, err := auth.ValidateJWTWithRole(bearerToken, cfg.secret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+		if err != nil || user.DeletedAt.Valid {
+			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		// Banned and suspended staff lose access at once too, not only
+		// when their refresh token is revoked.
+		if msg := signInRestriction(user); msg != "" {
+			respondWithError(w, http.StatusForbidden, msg)
+			return
+		}
+		role := auth.Role(user.Role)
+		if !role.Can(permission) {
+			respondWithError(w, http.StatusForbidden, "403 Forbidden")
+			return
+		}
+		ctx := context.WithValue(r.Context(), authenticatedUserKey, authenticatedUser{ID: userID, Role: role})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
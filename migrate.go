@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed sql/schema/*.sql
+var embeddedMigrations embed.FS
+
+// migrationsFS holds the goose migrations this binary was built with.
+func migrationsFS() fs.FS {
+	sub, err := fs.Sub(embeddedMigrations, "sql/schema")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// latestMigration is the version of the newest embedded migration, which
+// is the schema version this build expects.
+func latestMigration() int64 {
+	entries, err := fs.ReadDir(migrationsFS(), ".")
+	if err != nil {
+		panic(err)
+	}
+	var latest int64
+	for _, entry := range entries {
+		if version, err := goose.NumericComponent(entry.Name()); err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// newMigrator applies the embedded migrations to db. Migrations hold a
+// Postgres advisory lock, so instances starting together with
+// --auto-migrate take turns instead of racing.
+func newMigrator(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, migrationsFS(), goose.WithSessionLocker(locker))
+}
+
+// autoMigrate applies pending migrations before the server starts.
+func autoMigrate(ctx context.Context, db *sql.DB) error {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	results, err := migrator.Up(ctx)
+	for _, result := range results {
+		slog.Info("applied migration", "version", result.Source.Version, "duration_ms", result.Duration.Milliseconds())
+	}
+	return err
+}
+
+const migrateUsage = "usage: chirpy migrate up|down|status|redo"
+
+// runMigrateCommand implements "chirpy migrate":
+//
+//	up      apply every pending migration
+//	down    roll back the latest migration
+//	status  list migrations and when they were applied
+//	redo    roll back the latest migration and apply it again
+func runMigrateCommand(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	printResult := func(result *goose.MigrationResult) {
+		if result != nil {
+			fmt.Fprintf(out, "%-4s %s (%s)\n", result.Direction, result.Source.Path, result.Duration.Round(time.Millisecond))
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		for _, result := range results {
+			printResult(result)
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+	case "down":
+		result, err := migrator.Down(ctx)
+		printResult(result)
+		return err
+	case "redo":
+		result, err := migrator.Down(ctx)
+		printResult(result)
+		if err != nil {
+			return err
+		}
+		result, err = migrator.UpByOne(ctx)
+		printResult(result)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := ""
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.Source.Path, status.State, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
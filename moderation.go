@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/moderation"
+)
+
+func moderationMatchesFromContext(ctx context.Context) []moderation.Match {
+	matches, _ := ctx.Value(moderationMatchesKey).([]moderation.Match)
+	return matches
+}
+
+// reloadModeration rebuilds the moderation engine from the words file, if
+// one is configured, and the moderation_words table. Database entries win
+// over file entries for the same term.
+func (cfg *apiConfig) reloadModeration(ctx context.Context) error {
+	rules, err := cfg.moderationRules(ctx)
+	if err != nil {
+		return err
+	}
+	return cfg.moderation.Load(rules)
+}
+
+// moderationRules merges the words file and the moderation_words table.
+// Stored words that can't be compiled are logged and skipped, so that a bad
+// row can't keep the server from starting.
+func (cfg *apiConfig) moderationRules(ctx context.Context) ([]moderation.Rule, error) {
+	var rules []moderation.Rule
+	if cfg.moderationWordsFile != "" {
+		fileRules, err := moderation.LoadFile(cfg.moderationWordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load %s: %w", cfg.moderationWordsFile, err)
+		}
+		rules = append(rules, fileRules...)
+	}
+	words, err := cfg.dbQueries.ListModerationWords(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not load moderation words: %w", err)
+	}
+	for _, word := range words {
+		rule := moderation.Rule{Term: word.Term, Action: moderation.Action(word.Action)}
+		if err := rule.Validate(); err != nil {
+			loggerFrom(ctx).Warn("skipping invalid moderation word", "id", word.ID, "error", err)
+			continue
+		}
+		rules = mergeModerationRule(rules, rule)
+	}
+	return rules, nil
+}
+
+// mergeModerationRule replaces the rule for the same term, or appends rule.
+func mergeModerationRule(rules []moderation.Rule, rule moderation.Rule) []moderation.Rule {
+	for i := range rules {
+		if rules[i].Term == rule.Term {
+			rules[i] = rule
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// recordModerationFlags queues a chirp for review for every flag match.
+func recordModerationFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, matches []moderation.Match) error {
+	for _, match := range matches {
+		if match.Action != moderation.ActionFlag {
+			continue
+		}
+		_, err := q.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+			ChirpID: chirpID,
+			Source:  "moderation_words",
+			Reason:  fmt.Sprintf("contains %q", match.Term),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) listModerationWords(w http.ResponseWriter, r *http.Request) {
+	words, err := cfg.dbQueries.ListModerationWords(r.Context())
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve moderation words", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve moderation words")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"words":        words,
+		"active_rules": cfg.moderation.Rules(),
+	})
+}
+
+func (cfg *apiConfig) upsertModerationWord(w http.ResponseWriter, r *http.Request) {
+	var p moderation.Rule
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	p.Term = strings.TrimSpace(p.Term)
+	if p.Term == "" || !p.Action.Valid() {
+		respondWithError(w, http.StatusBadRequest, "term and a valid action (mask, reject or flag) are required")
+		return
+	}
+	// Compile the rule set it would produce first, so that a term the
+	// engine can't load never reaches the table.
+	rules, err := cfg.moderationRules(r.Context())
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not load moderation rules", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not save moderation word")
+		return
+	}
+	if _, err := moderation.NewEngine(mergeModerationRule(rules, p)); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	word, err := cfg.dbQueries.UpsertModerationWord(r.Context(), database.UpsertModerationWordParams{
+		Term:   p.Term,
+		Action: string(p.Action),
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not save moderation word", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not save moderation word")
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		loggerFrom(r.Context()).Error("saved moderation word but could not reload rules", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "saved moderation word but could not reload rules")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, word)
+}
+
+func (cfg *apiConfig) deleteModerationWord(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find moderation word")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteModerationWord(r.Context(), id)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not delete moderation word", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete moderation word")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "could not find moderation word")
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		loggerFrom(r.Context()).Error("deleted moderation word but could not reload rules", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "deleted moderation word but could not reload rules")
+		return
+	}
+	RespondNoContent(w, r)
+}
+
+func (cfg *apiConfig) handleModerationReload(w http.ResponseWriter, r *http.Request) {
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		loggerFrom(r.Context()).Error("could not reload moderation rules", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not reload moderation rules")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"active_rules": cfg.moderation.Rules(),
+	})
+}
+
+func (cfg *apiConfig) listModerationFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := cfg.dbQueries.ListOpenModerationFlags(r.Context())
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve moderation flags", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve moderation flags")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, flags)
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/tracing"
+	"github.com/wilgnert/chirpy/internal/webhooks"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	webhookDeliveryBatch   = 20
+	webhookDeliveryTimeout = 10 * time.Second
+	// webhookDeliveryLease is how long a claimed delivery is hidden from
+	// other workers. It must outlast webhookDeliveryTimeout, which is how
+	// long a whole batch takes at most since it is sent concurrently.
+	webhookDeliveryLease = time.Minute
+)
+
+// webhookEnvelope is the JSON body of every outbound webhook.
+type webhookEnvelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func newWebhookPayload(event string, data any) (string, error) {
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:        uuid.NewString(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	return string(payload), err
+}
+
+func chirpDeletedEvent(chirp database.Chirp) map[string]any {
+	return map[string]any{
+		"id":      chirp.ID.String(),
+		"user_id": chirp.UserID.String(),
+	}
+}
+
+// emitEvent queues event for every endpoint userID registered for it.
+// user.mentioned and user.followed can be subscribed to but are not emitted
+// yet: chirpy has neither handles to mention nor follows.
+// Failing to queue is logged rather than failing the request that caused
+// the event.
+func (cfg *apiConfig) emitEvent(ctx context.Context, userID uuid.UUID, event string, data any) {
+	payload, err := newWebhookPayload(event, data)
+	if err == nil {
+		_, err = cfg.dbQueries.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+			UserID:       userID,
+			EventType:    event,
+			Payload:      payload,
+			TraceContext: tracing.Inject(ctx),
+		})
+	}
+	if err != nil {
+		loggerFrom(ctx).Error("queueing webhooks failed", "event", event, "error", err)
+	}
+}
+
+// runWebhookDispatcher sends due deliveries every interval until ctx is
+// done.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	client := webhooks.NewClient(webhookDeliveryTimeout, cfg.plataform == "dev")
+	client.Transport = tracing.Transport(client.Transport)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deliveries, err := cfg.dbQueries.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(webhookDeliveryLease),
+			BatchSize:  webhookDeliveryBatch,
+		})
+		if err != nil {
+			loggerFrom(ctx).Error("claiming webhook deliveries failed", "error", err)
+			continue
+		}
+		// The batch is sent concurrently so that it finishes within one
+		// webhookDeliveryTimeout, well inside the lease; sent one at a time
+		// it could outlast the lease and be claimed again by another
+		// instance. Deliveries in flight when ctx is done are allowed to
+		// finish.
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := cfg.sendWebhookDelivery(context.WithoutCancel(ctx), client, delivery); err != nil {
+					loggerFrom(ctx).Error("recording webhook delivery failed", "delivery_id", delivery.ID, "error", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// sendWebhookDelivery makes one attempt at a delivery and logs it. Failed
+// deliveries are retried with exponential backoff until they run out of
+// attempts and are dead-lettered. The attempt joins the trace of the request
+// that emitted the event.
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, client *http.Client, delivery database.WebhookDelivery) error {
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, delivery.TraceContext), "webhook.deliver",
+		trace.WithAttributes(
+			attribute.String("webhook.event", delivery.EventType),
+			attribute.String("webhook.delivery_id", delivery.ID.String()),
+			attribute.Int("webhook.attempt", int(delivery.Attempts)+1),
+		),
+	)
+	defer span.End()
+
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+	attemptedAt := time.Now()
+	res, sendErr := webhooks.Send(ctx, client, webhooks.Delivery{
+		ID:      delivery.ID.String(),
+		Event:   delivery.EventType,
+		URL:     endpoint.Url,
+		Secret:  endpoint.Secret,
+		Payload: []byte(delivery.Payload),
+	})
+
+	statusCode := sql.NullInt32{Int32: int32(res.StatusCode), Valid: res.StatusCode != 0}
+	var errMsg sql.NullString
+	if sendErr != nil {
+		errMsg = sql.NullString{String: sendErr.Error(), Valid: true}
+		span.SetStatus(codes.Error, sendErr.Error())
+	}
+	err = cfg.dbQueries.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID:  delivery.ID,
+		AttemptedAt: attemptedAt,
+		DurationMs:  int32(res.Duration.Milliseconds()),
+		StatusCode:  statusCode,
+		Error:       errMsg,
+	})
+	if err != nil {
+		return err
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	status, nextAttempt := "succeeded", attemptedAt
+	if sendErr != nil {
+		status, nextAttempt = "pending", attemptedAt.Add(webhooks.Backoff(attempts))
+		if attempts >= webhooks.MaxAttempts {
+			status = "dead"
+		}
+	}
+	outcome := status
+	if status == "pending" {
+		outcome = "retrying"
+	}
+	cfg.metrics.WebhookOutcomes.WithLabelValues("outbound", outcome).Inc()
+	return cfg.dbQueries.FinishWebhookDeliveryAttempt(ctx, database.FinishWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  nextAttempt,
+		LastStatusCode: statusCode,
+		LastError:      errMsg,
+	})
+}
+
+// validateWebhookURL only allows plain http outside of production-like
+// platforms, so that signed payloads aren't sent in the clear. Outside of
+// dev the host must also resolve to public addresses only.
+func (cfg *apiConfig) validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && cfg.plataform == "dev":
+	default:
+		return errors.New("url must use https")
+	}
+	if cfg.plataform == "dev" {
+		return nil
+	}
+	if err := webhooks.CheckHost(ctx, u.Hostname()); errors.Is(err, webhooks.ErrBlockedAddress) {
+		return errors.New("url must point at a public address")
+	} else if err != nil {
+		return errors.New("url host could not be resolved")
+	}
+	return nil
+}
+
+func webhookEndpointResponse(endpoint database.WebhookEndpoint) map[string]any {
+	return map[string]any{
+		"id":         endpoint.ID.String(),
+		"created_at": endpoint.CreatedAt.String(),
+		"updated_at": endpoint.UpdatedAt.String(),
+		"url":        endpoint.Url,
+		"events":     endpoint.Events,
+	}
+}
+
+func webhookDeliveryResponse(delivery database.WebhookDelivery) map[string]any {
+	res := map[string]any{
+		"id":               delivery.ID.String(),
+		"created_at":       delivery.CreatedAt.String(),
+		"event_type":       delivery.EventType,
+		"payload":          json.RawMessage(delivery.Payload),
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"next_attempt_at":  nil,
+		"last_status_code": nil,
+		"last_error":       nil,
+	}
+	if delivery.Status == "pending" {
+		res["next_attempt_at"] = delivery.NextAttemptAt.String()
+	}
+	if delivery.LastStatusCode.Valid {
+		res["last_status_code"] = delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		res["last_error"] = delivery.LastError.String
+	}
+	return res
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	var p struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	if err := cfg.validateWebhookURL(r.Context(), p.URL); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(p.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one event is required")
+		return
+	}
+	for _, event := range p.Events {
+		if !webhooks.ValidEvent(event) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown event %q", event))
+			return
+		}
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not create webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create webhook")
+		return
+	}
+	endpoint, err := cfg.dbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    p.URL,
+		Secret: secret,
+		Events: p.Events,
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not create webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create webhook")
+		return
+	}
+	// The secret is only ever shown here.
+	res := webhookEndpointResponse(endpoint)
+	res["secret"] = endpoint.Secret
+	respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) listWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	endpoints, err := cfg.dbQueries.ListWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve webhooks", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve webhooks")
+		return
+	}
+	res := make([]map[string]any, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		res = append(res, webhookEndpointResponse(endpoint))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{ID: endpointID, UserID: userID})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not delete webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not delete webhook")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "could not find webhook")
+		return
+	}
+	RespondNoContent(w, r)
+}
+
+// ownWebhookEndpoint loads the endpoint in the path if it belongs to the
+// caller, responding with an error otherwise.
+func (cfg *apiConfig) ownWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return database.WebhookEndpoint{}, false
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return database.WebhookEndpoint{}, false
+	}
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook")
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil || endpoint.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "could not find webhook")
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.ownWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := cfg.dbQueries.ListWebhookDeliveriesForEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve deliveries", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve deliveries")
+		return
+	}
+	res := make([]map[string]any, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		res = append(res, webhookDeliveryResponse(delivery))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// ownWebhookDelivery loads the delivery in the path if it was sent to the
+// caller's endpoint in the path.
+func (cfg *apiConfig) ownWebhookDelivery(w http.ResponseWriter, r *http.Request) (database.WebhookDelivery, bool) {
+	endpoint, ok := cfg.ownWebhookEndpoint(w, r)
+	if !ok {
+		return database.WebhookDelivery{}, false
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find delivery")
+		return database.WebhookDelivery{}, false
+	}
+	delivery, err := cfg.dbQueries.GetWebhookDelivery(r.Context(), deliveryID)
+	if err != nil || delivery.EndpointID != endpoint.ID {
+		respondWithError(w, http.StatusNotFound, "could not find delivery")
+		return database.WebhookDelivery{}, false
+	}
+	return delivery, true
+}
+
+func (cfg *apiConfig) getWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := cfg.ownWebhookDelivery(w, r)
+	if !ok {
+		return
+	}
+	attempts, err := cfg.dbQueries.ListWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve delivery attempts", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve delivery attempts")
+		return
+	}
+	log := make([]map[string]any, 0, len(attempts))
+	for _, attempt := range attempts {
+		entry := map[string]any{
+			"attempted_at": attempt.AttemptedAt.String(),
+			"duration_ms":  attempt.DurationMs,
+			"status_code":  nil,
+			"error":        nil,
+		}
+		if attempt.StatusCode.Valid {
+			entry["status_code"] = attempt.StatusCode.Int32
+		}
+		if attempt.Error.Valid {
+			entry["error"] = attempt.Error.String
+		}
+		log = append(log, entry)
+	}
+	res := webhookDeliveryResponse(delivery)
+	res["attempt_log"] = log
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// redeliverWebhook puts a dead-lettered delivery back in the queue with a
+// fresh set of attempts.
+func (cfg *apiConfig) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := cfg.ownWebhookDelivery(w, r)
+	if !ok {
+		return
+	}
+	delivery, err := cfg.dbQueries.RedeliverWebhookDelivery(r.Context(), delivery.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "only dead deliveries can be redelivered")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not redeliver webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not redeliver webhook")
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryResponse(delivery))
+}
+
+// sendTestWebhook queues a webhook.test event for the endpoint, regardless
+// of the events it subscribed to.
+func (cfg *apiConfig) sendTestWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.ownWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+	payload, err := newWebhookPayload(webhooks.EventTest, map[string]any{
+		"endpoint_id": endpoint.ID.String(),
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not send test event", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not send test event")
+		return
+	}
+	delivery, err := cfg.dbQueries.CreateWebhookDelivery(r.Context(), database.CreateWebhookDeliveryParams{
+		EndpointID:   endpoint.ID,
+		EventType:    webhooks.EventTest,
+		Payload:      payload,
+		TraceContext: tracing.Inject(r.Context()),
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not send test event", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not send test event")
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryResponse(delivery))
+}
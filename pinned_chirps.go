@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+// pinChirp pins one of the caller's chirps to their profile, up to the
+// max_pinned_chirps of their tier.
+func (cfg *apiConfig) pinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), database.GetChirpByIDParams{ID: chirpID, ViewerID: uuid.NullUUID{UUID: userID, Valid: true}})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "you can only pin your own chirps")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not pin chirp", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	// Locking the user serializes concurrent pins against the limit.
+	user, err := qtx.GetUserByIDForUpdate(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	perks := cfg.perksFor(user)
+	if perks.MaxPinnedChirps == 0 {
+		respondWithError(w, http.StatusForbidden, "pinning chirps requires Chirpy Red")
+		return
+	}
+	pinned, err := qtx.CountPinnedChirps(r.Context(), userID)
+	if err == nil && pinned >= int64(perks.MaxPinnedChirps) {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("you can pin at most %d chirps", perks.MaxPinnedChirps))
+		return
+	}
+	if err == nil {
+		err = qtx.PinChirp(r.Context(), database.PinChirpParams{UserID: userID, ChirpID: chirpID})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not pin chirp", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp")
+		return
+	}
+	RespondNoContent(w, r)
+}
+
+func (cfg *apiConfig) unpinChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	unpinned, err := cfg.dbQueries.UnpinChirp(r.Context(), database.UnpinChirpParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not unpin chirp", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not unpin chirp")
+		return
+	}
+	if unpinned == 0 {
+		respondWithError(w, http.StatusNotFound, "chirp is not pinned")
+		return
+	}
+	RespondNoContent(w, r)
+}
+
+// getPinnedChirps lists a user's pinned chirps. Pins are kept when Chirpy
+// Red lapses but only shown up to the limit of the user's current tier.
+func (cfg *apiConfig) getPinnedChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	perks, err := cfg.perksForUserID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	chirps, err := cfg.dbQueries.GetPinnedChirpsForUser(r.Context(), database.GetPinnedChirpsForUserParams{
+		UserID:    userID,
+		ViewerID:  cfg.viewerID(r),
+		MaxPinned: int32(perks.MaxPinnedChirps),
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve chirps", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/ratelimit"
+)
+
+// rateLimitBudgets are the per-client budgets of each route group.
+// Requests are limited per IP and, when authenticated, per user as well, so
+// that switching accounts doesn't get around the limit. Authenticated
+// budgets are scaled by the user's rate_limit_multiplier.
+var rateLimitBudgets = map[string]ratelimit.Limit{
+	"auth":  {Requests: 10, Per: time.Minute},
+	"write": {Requests: 30, Per: time.Minute},
+	"read":  {Requests: 120, Per: time.Minute},
+}
+
+func (cfg *apiConfig) middlewareRateLimit(group string, next http.Handler) http.Handler {
+	limit, ok := rateLimitBudgets[group]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit group %q", group))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{"ip:" + cfg.clientIP(r)}
+		groupLimit := limit
+		if bearerToken, err := auth.GetBearerToken(r.Header); err == nil {
+			if userID, err := cfg.validateAccessToken(r.Context(), bearerToken); err == nil {
+				keys = append(keys, "user:"+userID.String())
+				if perks, err := cfg.perksForUserID(r.Context(), userID); err == nil {
+					groupLimit = limit.Scale(perks.RateLimitMultiplier)
+				}
+			}
+		}
+
+		// Every bucket is charged, and the headers describe whichever is
+		// closest to running out.
+		var res ratelimit.Result
+		for i, key := range keys {
+			keyRes, err := cfg.rateLimiter.Allow(r.Context(), group+":"+key, groupLimit)
+			if err != nil {
+				// Fail open: an unavailable limiter shouldn't take the API down.
+				loggerFrom(r.Context()).Error("checking rate limit failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if i == 0 || stricterRateLimit(keyRes, res) {
+				res = keyRes
+			}
+		}
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+			respondWithError(w, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stricterRateLimit reports whether a is more restrictive than b: it
+// rejected the request when b didn't, or it has fewer requests left.
+func stricterRateLimit(a, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// trusted when the server is configured to run behind a proxy, since
+// clients can set it to anything.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// pruneRateLimits drops idle buckets of the Postgres limiter. It is only
+// scheduled when that limiter is in use.
+func (cfg *apiConfig) pruneRateLimits(ctx context.Context, _ struct{}) error {
+	limiter, ok := cfg.rateLimiter.(*ratelimit.PostgresLimiter)
+	if !ok {
+		return nil
+	}
+	_, err := limiter.Prune(ctx, time.Hour)
+	return err
+}
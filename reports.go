@@ -0,0 +1,370 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/webhooks"
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate_speech":    true,
+	"misinformation": true,
+	"other":          true,
+}
+
+const defaultSuspension = 7 * 24 * time.Hour
+
+func nullableUUID(id uuid.NullUUID) any {
+	if !id.Valid {
+		return nil
+	}
+	return id.UUID.String()
+}
+
+func nullableTime(t sql.NullTime) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.String()
+}
+
+func reportResponse(report database.Report) map[string]any {
+	res := map[string]any{
+		"id":            report.ID.String(),
+		"created_at":    report.CreatedAt.String(),
+		"updated_at":    report.UpdatedAt.String(),
+		"chirp_id":      nullableUUID(report.ChirpID),
+		"chirp_body":    report.ChirpBody,
+		"chirp_user_id": report.ChirpUserID.String(),
+		"reporter_id":   report.ReporterID.String(),
+		"reason":        report.Reason,
+		"details":       report.Details,
+		"status":        report.Status,
+		"claimed_by":    nullableUUID(report.ClaimedBy),
+		"claimed_at":    nullableTime(report.ClaimedAt),
+		"resolved_by":   nullableUUID(report.ResolvedBy),
+		"resolved_at":   nullableTime(report.ResolvedAt),
+		"resolution":    nil,
+	}
+	if report.Resolution.Valid {
+		res["resolution"] = report.Resolution.String
+	}
+	return res
+}
+
+func (cfg *apiConfig) createReport(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	reporterID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	var p struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	if !reportReasons[p.Reason] {
+		respondWithError(w, http.StatusBadRequest, "reason must be one of spam, harassment, hate_speech, misinformation or other")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: reporterID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not retrieve chirp")
+		return
+	}
+
+	report, err := cfg.dbQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:   chirp.Body,
+		ChirpUserID: chirp.UserID,
+		ReporterID:  reporterID,
+		Reason:      p.Reason,
+		Details:     p.Details,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "you already reported this chirp")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not create report at this time", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not create report at this time")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]any{
+		"id":         report.ID.String(),
+		"created_at": report.CreatedAt.String(),
+		"chirp_id":   chirp.ID.String(),
+		"reason":     report.Reason,
+		"status":     report.Status,
+	})
+}
+
+func (cfg *apiConfig) listReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	reports, err := cfg.dbQueries.ListReportsByStatus(r.Context(), status)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve reports", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve reports")
+		return
+	}
+	res := make([]map[string]any, 0, len(reports))
+	for _, report := range reports {
+		res = append(res, reportResponse(report))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) claimReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find report")
+		return
+	}
+	caller, _ := authenticatedUserFromContext(r.Context())
+	actorID := caller.ID
+	report, err := cfg.dbQueries.ClaimReport(r.Context(), database.ClaimReportParams{
+		ID:        reportID,
+		ClaimedBy: uuid.NullUUID{UUID: actorID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "report is not open")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not claim report", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not claim report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, reportResponse(report))
+}
+
+// resolveReport closes a report with one of the moderation actions. The
+// action, the report resolution and the audit record in moderation_actions
+// are written in a single transaction.
+func (cfg *apiConfig) resolveReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find report")
+		return
+	}
+	caller, _ := authenticatedUserFromContext(r.Context())
+	actorID := caller.ID
+	var p struct {
+		Action         string     `json:"action"`
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&p); err != nil {
+		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
+		}
+		return
+	}
+	if p.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "a reason is required")
+		return
+	}
+	if p.SuspendedUntil != nil && !p.SuspendedUntil.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future")
+		return
+	}
+
+	report, err := cfg.dbQueries.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find report")
+		return
+	}
+	if !checkReportResolvable(w, caller, report) {
+		return
+	}
+	target, err := cfg.dbQueries.GetUserByID(r.Context(), report.ChirpUserID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find user")
+		return
+	}
+	if !checkModerationTarget(w, caller, target) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not resolve report", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not resolve report")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	actor := uuid.NullUUID{UUID: actorID, Valid: true}
+	switch p.Action {
+	case "hide_chirp", "delete_chirp":
+		if !report.ChirpID.Valid {
+			respondWithError(w, http.StatusConflict, "reported chirp no longer exists")
+			return
+		}
+		if p.Action == "hide_chirp" {
+			err = qtx.HideChirp(r.Context(), database.HideChirpParams{ChirpID: report.ChirpID.UUID, HiddenBy: actor, Reason: p.Reason})
+		} else {
+			err = qtx.DeleteChirpByID(r.Context(), report.ChirpID.UUID)
+		}
+	case "suspend_user":
+		until := time.Now().Add(defaultSuspension)
+		if p.SuspendedUntil != nil {
+			until = *p.SuspendedUntil
+		}
+		err = suspendAccount(r.Context(), qtx, report.ChirpUserID, until)
+	case "warn_user", "dismiss":
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be one of hide_chirp, delete_chirp, warn_user, suspend_user or dismiss")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not resolve report", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not resolve report")
+		return
+	}
+
+	resolved, err := qtx.ResolveReport(r.Context(), database.ResolveReportParams{
+		ID:         reportID,
+		ResolvedBy: actor,
+		Resolution: sql.NullString{String: p.Action, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Resolved or claimed by someone else since it was read.
+		respondWithError(w, http.StatusConflict, "report is already resolved or claimed by someone else")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not resolve report", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not resolve report")
+		return
+	}
+	_, err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ActorID:       actor,
+		ReportID:      uuid.NullUUID{UUID: reportID, Valid: true},
+		Action:        p.Action,
+		TargetUserID:  uuid.NullUUID{UUID: report.ChirpUserID, Valid: true},
+		TargetChirpID: report.ChirpID,
+		Reason:        p.Reason,
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not resolve report", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not resolve report")
+		return
+	}
+	if p.Action == "delete_chirp" {
+		cfg.emitEvent(r.Context(), report.ChirpUserID, webhooks.EventChirpDeleted, map[string]any{
+			"id":      report.ChirpID.UUID.String(),
+			"user_id": report.ChirpUserID.String(),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, reportResponse(resolved))
+}
+
+// checkReportResolvable refuses reports that are already resolved, or that
+// another moderator claimed.
+func checkReportResolvable(w http.ResponseWriter, caller authenticatedUser, report database.Report) bool {
+	switch {
+	case report.Status == "resolved":
+		respondWithError(w, http.StatusConflict, "report is already resolved")
+		return false
+	case report.Status == "claimed" && report.ClaimedBy.UUID != caller.ID:
+		respondWithError(w, http.StatusConflict, "report is claimed by another moderator")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) listReportActions(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find report")
+		return
+	}
+	actions, err := cfg.dbQueries.ListModerationActionsForReport(r.Context(), uuid.NullUUID{UUID: reportID, Valid: true})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve moderation actions", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve moderation actions")
+		return
+	}
+	res := make([]map[string]any, 0, len(actions))
+	for _, action := range actions {
+		res = append(res, map[string]any{
+			"id":              action.ID.String(),
+			"created_at":      action.CreatedAt.String(),
+			"actor_id":        nullableUUID(action.ActorID),
+			"action":          action.Action,
+			"target_user_id":  nullableUUID(action.TargetUserID),
+			"target_chirp_id": nullableUUID(action.TargetChirpID),
+			"reason":          action.Reason,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) listMyWarnings(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	userID, err := cfg.validateAccessToken(r.Context(), bearerToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	warnings, err := cfg.dbQueries.ListWarningsForUser(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve warnings", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve warnings")
+		return
+	}
+	res := make([]map[string]any, 0, len(warnings))
+	for _, warning := range warnings {
+		res = append(res, map[string]any{
+			"id":         warning.ID.String(),
+			"created_at": warning.CreatedAt.String(),
+			"reason":     warning.Reason,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/tracing"
+)
+
+// handler registers every route and wraps them in the middlewares that
+// apply to all requests.
+func (cfg *apiConfig) handler() http.Handler {
+	fileserverHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
+	respondOkHandler := http.HandlerFunc(RespondOK)
+
+	mux := http.NewServeMux()
+	mux.Handle("/app/", cfg.middlewareMetricsInc(fileserverHandler))
+	mux.HandleFunc("GET /admin/healthz", handleLiveness)
+	mux.HandleFunc("GET /admin/readyz", cfg.handleReadiness)
+	mux.Handle("GET /metrics", cfg.middlewareMetricsAuth(cfg.metrics.Handler()))
+	mux.Handle("POST /admin/reset", cfg.middlewareRequirePermission(auth.PermissionResetData, cfg.resetMetricsMiddleware(respondOkHandler)))
+	// mux.Handle("POST /api/validate_chirp", badWordsReplacementMiddleware(http.HandlerFunc(chripyValidator)))
+	mux.Handle("GET /admin/moderation/words", cfg.middlewareRequirePermission(auth.PermissionManageModeration, http.HandlerFunc(cfg.listModerationWords)))
+	mux.Handle("POST /admin/moderation/words", cfg.middlewareRequirePermission(auth.PermissionManageModeration, http.HandlerFunc(cfg.upsertModerationWord)))
+	mux.Handle("DELETE /admin/moderation/words/{wordID}", cfg.middlewareRequirePermission(auth.PermissionManageModeration, http.HandlerFunc(cfg.deleteModerationWord)))
+	mux.Handle("POST /admin/moderation/reload", cfg.middlewareRequirePermission(auth.PermissionManageModeration, http.HandlerFunc(cfg.handleModerationReload)))
+	mux.Handle("GET /admin/moderation/flags", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.listModerationFlags)))
+	mux.Handle("GET /admin/spam/detections", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.listSpamDetections)))
+	mux.Handle("GET /admin/webhooks/events", cfg.middlewareRequirePermission(auth.PermissionManageWebhooks, http.HandlerFunc(cfg.listWebhookEvents)))
+	mux.Handle("GET /admin/webhooks/events/{eventID}", cfg.middlewareRequirePermission(auth.PermissionManageWebhooks, http.HandlerFunc(cfg.getWebhookEvent)))
+	mux.Handle("POST /admin/webhooks/events/{eventID}/replay", cfg.middlewareRequirePermission(auth.PermissionManageWebhooks, http.HandlerFunc(cfg.replayWebhookEvent)))
+	mux.Handle("GET /admin/reports", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.listReports)))
+	mux.Handle("POST /admin/reports/{reportID}/claim", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.claimReport)))
+	mux.Handle("POST /admin/reports/{reportID}/resolve", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.resolveReport)))
+	mux.Handle("GET /admin/users", cfg.middlewareRequirePermission(auth.PermissionManageUsers, http.HandlerFunc(cfg.listUsers)))
+	mux.Handle("PUT /admin/users/{userID}/role", cfg.middlewareRequirePermission(auth.PermissionManageUsers, http.HandlerFunc(cfg.updateUserRole)))
+	mux.Handle("POST /admin/users/{userID}/suspend", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.suspendUser)))
+	mux.Handle("DELETE /admin/users/{userID}/suspend", cfg.middlewareRequirePermission(auth.PermissionModerateContent, cfg.restrictUser("unsuspend_user")))
+	mux.Handle("POST /admin/users/{userID}/ban", cfg.middlewareRequirePermission(auth.PermissionModerateContent, cfg.restrictUser("ban_user")))
+	mux.Handle("DELETE /admin/users/{userID}/ban", cfg.middlewareRequirePermission(auth.PermissionModerateContent, cfg.restrictUser("unban_user")))
+	mux.Handle("POST /admin/users/{userID}/shadow-ban", cfg.middlewareRequirePermission(auth.PermissionModerateContent, cfg.restrictUser("shadow_ban_user")))
+	mux.Handle("DELETE /admin/users/{userID}/shadow-ban", cfg.middlewareRequirePermission(auth.PermissionModerateContent, cfg.restrictUser("unshadow_ban_user")))
+	mux.Handle("POST /admin/users/{userID}/sessions/revoke", cfg.middlewareRequirePermission(auth.PermissionManageUsers, http.HandlerFunc(cfg.revokeUserSessions)))
+	mux.Handle("GET /admin/reports/{reportID}/actions", cfg.middlewareRequirePermission(auth.PermissionModerateContent, http.HandlerFunc(cfg.listReportActions)))
+
+	mux.Handle("POST /api/users", cfg.middlewareRateLimit("auth", http.HandlerFunc(cfg.createUser)))
+	mux.Handle("PUT /api/users", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.updateUserEmailAndPassword)))
+	mux.Handle("DELETE /api/users/me", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.deleteAccount)))
+	mux.Handle("POST /api/users/restore", cfg.middlewareRateLimit("auth", http.HandlerFunc(cfg.restoreAccount)))
+	mux.Handle("POST /api/users/me/exports", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.requestDataExport)))
+	mux.Handle("GET /api/users/me/exports/{exportID}", http.HandlerFunc(cfg.getDataExport))
+	mux.Handle("GET /api/users/me/warnings", http.HandlerFunc(cfg.listMyWarnings))
+	mux.Handle("GET /api/exports/{exportID}/download", http.HandlerFunc(cfg.downloadDataExport))
+
+
+	mux.Handle("POST /api/login", cfg.middlewareRateLimit("auth", http.HandlerFunc(cfg.login)))
+	mux.Handle("POST /api/refresh", cfg.middlewareRateLimit("auth", http.HandlerFunc(cfg.refresh)))
+	mux.Handle("POST /api/revoke", http.HandlerFunc(cfg.revoke))
+
+	mux.Handle("GET /api/chirps", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.getAllChirps)))
+	mux.Handle("GET /api/chirps/{chirpID}", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.getChirpByID)))
+	mux.Handle("PUT /api/chirps/{chirpID}", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.updateChirp)))
+	mux.Handle("POST /api/chirps/{chirpID}/pin", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.pinChirp)))
+	mux.Handle("DELETE /api/chirps/{chirpID}/pin", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.unpinChirp)))
+	mux.Handle("GET /api/users/{userID}/pinned_chirps", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.getPinnedChirps)))
+	mux.Handle("DELETE /api/chirps/{chirpID}", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.deleteChirpByID)))
+	mux.Handle("POST /api/chirps/{chirpID}/reports", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.createReport)))
+	mux.Handle("POST /api/chirps/import", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.handleChirpImport)))
+	mux.Handle("POST /api/chirps", cfg.middlewareRateLimit("write", cfg.moderationMiddleware(cfg.chripyValidatorMiddleware(http.HandlerFunc(cfg.createChirp)))))
+
+	mux.Handle("POST /api/webhooks", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.createWebhookEndpoint)))
+	mux.Handle("GET /api/webhooks", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.listWebhookEndpoints)))
+	mux.Handle("DELETE /api/webhooks/{endpointID}", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.deleteWebhookEndpoint)))
+	mux.Handle("POST /api/webhooks/{endpointID}/test", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.sendTestWebhook)))
+	mux.Handle("GET /api/webhooks/{endpointID}/deliveries", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.listWebhookDeliveries)))
+	mux.Handle("GET /api/webhooks/{endpointID}/deliveries/{deliveryID}", cfg.middlewareRateLimit("read", http.HandlerFunc(cfg.getWebhookDelivery)))
+	mux.Handle("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/redeliver", cfg.middlewareRateLimit("write", http.HandlerFunc(cfg.redeliverWebhook)))
+	mux.Handle("POST /api/polka/webhooks", http.HandlerFunc(cfg.handleWebhook))
+
+	return middlewareRequestID(tracing.Middleware(cfg.middlewareAccessLog(cfg.metrics.Middleware(mux))))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/wilgnert/chirpy/internal/config"
+)
+
+// newServer builds the API server from c. HTTP/2 is only offered over TLS.
+func newServer(c config.Server, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(c.HTTP2)
+	return &http.Server{
+		Addr:              c.Addr,
+		Handler:           handler,
+		Protocols:         protocols,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// newRedirectServer sends plain HTTP clients to the same URL over HTTPS on
+// the port the API listens on.
+func newRedirectServer(c config.Server) *http.Server {
+	_, port, _ := net.SplitHostPort(c.Addr)
+	return &http.Server{
+		Addr: c.RedirectAddr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if port != "" && port != "443" {
+				host = net.JoinHostPort(host, port)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+}
+
+// serve runs the API until it fails or the process gets SIGINT or SIGTERM.
+// On a signal, readiness starts failing right away; the server keeps
+// serving for the drain delay and then gives in-flight requests up to the
+// shutdown timeout to finish.
+func (cfg *apiConfig) serve(handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	servers := []*http.Server{newServer(cfg.server, handler)}
+	errs := make(chan error, 2)
+	go func() {
+		if cfg.server.TLS() {
+			errs <- servers[0].ListenAndServeTLS(cfg.server.TLSCertFile, cfg.server.TLSKeyFile)
+		} else {
+			errs <- servers[0].ListenAndServe()
+		}
+	}()
+	if cfg.server.RedirectAddr != "" {
+		redirect := newRedirectServer(cfg.server)
+		servers = append(servers, redirect)
+		go func() { errs <- redirect.ListenAndServe() }()
+		slog.Info("redirecting http to https", "addr", cfg.server.RedirectAddr)
+	}
+	slog.Info("starting server", "addr", cfg.server.Addr, "tls", cfg.server.TLS(), "http2", cfg.server.HTTP2)
+
+	var serveErr error
+	select {
+	case serveErr = <-errs:
+		cfg.shuttingDown.Store(true)
+	case <-ctx.Done():
+		// A second signal stops the process without waiting.
+		stop()
+		slog.Info("shutting down", "drain_delay", cfg.server.DrainDelay.String(), "timeout", cfg.server.ShutdownTimeout.String())
+		// Readiness fails for a while before the listener closes, so that
+		// load balancers notice and stop sending new requests here.
+		cfg.shuttingDown.Store(true)
+		time.Sleep(cfg.server.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.server.ShutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("could not drain requests in time", "addr", server.Addr, "error", err)
+			server.Close()
+		}
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return nil
+}
+
+// startWorkers starts the job workers and the webhook dispatcher until
+// shutdown is called.
+func (cfg *apiConfig) startWorkers() {
+	ctx, stop := context.WithCancel(context.Background())
+	cfg.stopWorkers = stop
+	if err := cfg.jobs.Start(ctx, 4); err != nil {
+		slog.Error("starting job workers failed", "error", err)
+	}
+	done := make(chan struct{})
+	cfg.dispatcherDone = done
+	go func() {
+		defer close(done)
+		cfg.runWebhookDispatcher(ctx, 5*time.Second)
+	}()
+}
+
+// shutdown stops the background workers, flushes traces and closes the
+// database pool. Jobs still running when ctx is done are picked up again by
+// another instance once their lock times out.
+func (cfg *apiConfig) shutdown(ctx context.Context) {
+	if cfg.stopWorkers != nil {
+		cfg.stopWorkers()
+	}
+	if err := cfg.jobs.Shutdown(ctx); err != nil {
+		slog.Error("job workers did not stop in time", "error", err)
+	}
+	if cfg.dispatcherDone != nil {
+		select {
+		case <-cfg.dispatcherDone:
+		case <-ctx.Done():
+			slog.Error("webhook dispatcher did not stop in time", "error", ctx.Err())
+		}
+	}
+	if err := cfg.shutdownTracing(ctx); err != nil {
+		slog.Error("could not flush traces", "error", err)
+	}
+	if err := cfg.db.Close(); err != nil {
+		slog.Error("could not close database", "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/config"
+	"github.com/wilgnert/chirpy/internal/database"
+	"github.com/wilgnert/chirpy/internal/spam"
+)
+
+// loadSpamDetector configures spam detection from c.
+func loadSpamDetector(c config.Spam) (*spam.Detector, error) {
+	cfg := spam.DefaultConfig()
+	cfg.Action = spam.Action(c.Action)
+	cfg.DuplicateWindow = c.DuplicateWindow
+	cfg.MaxDuplicates = c.MaxDuplicates
+	cfg.MaxURLs = c.MaxURLs
+	if c.BlockedDomainsFile != "" {
+		domains, err := spam.LoadDomains(c.BlockedDomainsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load %s: %w", c.BlockedDomainsFile, err)
+		}
+		cfg.BlockedDomains = domains
+	}
+	return spam.NewDetector(cfg)
+}
+
+// checkSpam compares body against the chirps userID posted within the
+// duplicate window. editedID is the chirp being edited, if any.
+func (cfg *apiConfig) checkSpam(ctx context.Context, userID uuid.UUID, editedID uuid.NullUUID, body string) (spam.Verdict, error) {
+	recent, err := cfg.dbQueries.GetRecentChirpBodiesByUser(ctx, database.GetRecentChirpBodiesByUserParams{
+		UserID:     userID,
+		Since:      time.Now().Add(-cfg.spam.DuplicateWindow),
+		ExcludedID: editedID,
+	})
+	if err != nil {
+		return spam.Verdict{}, err
+	}
+	return cfg.spam.Check(body, recent), nil
+}
+
+// handleSpam records a detection and applies the configured action to a
+// chirp that was created anyway. chirpID is unset for rejected chirps.
+func (cfg *apiConfig) handleSpam(ctx context.Context, userID uuid.UUID, chirpID uuid.NullUUID, body string, verdict spam.Verdict) error {
+	_, err := cfg.dbQueries.CreateSpamDetection(ctx, database.CreateSpamDetectionParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+		Body:    body,
+		Reasons: verdict.Reasons,
+		Action:  string(cfg.spam.Action),
+	})
+	if err != nil || !chirpID.Valid {
+		return err
+	}
+	reason := strings.Join(verdict.Reasons, "; ")
+	switch cfg.spam.Action {
+	case spam.ActionReview:
+		_, err = cfg.dbQueries.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+			ChirpID: chirpID.UUID,
+			Source:  "spam",
+			Reason:  reason,
+		})
+	case spam.ActionThrottle:
+		err = cfg.dbQueries.HideChirpSilently(ctx, database.HideChirpSilentlyParams{
+			ChirpID: chirpID.UUID,
+			Reason:  "spam: " + reason,
+		})
+	}
+	return err
+}
+
+func (cfg *apiConfig) listSpamDetections(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = parsed
+	}
+	detections, err := cfg.dbQueries.ListSpamDetections(r.Context(), since)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve spam detections", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve spam detections")
+		return
+	}
+	res := make([]map[string]any, 0, len(detections))
+	for _, detection := range detections {
+		res = append(res, map[string]any{
+			"id":         detection.ID.String(),
+			"created_at": detection.CreatedAt.String(),
+			"user_id":    detection.UserID.String(),
+			"chirp_id":   nullableUUID(detection.ChirpID),
+			"body":       detection.Body,
+			"reasons":    detection.Reasons,
+			"action":     detection.Action,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,13 @@
+-- name: CreateImportedChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(), $1, $1, $2, $3
+)
+RETURNING *;
+
+-- name: CreateChirpImport :exec
+INSERT INTO chirp_imports (user_id, source_id, chirp_id, imported_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: GetChirpImport :one
+select * from chirp_imports where user_id = $1 and source_id = $2;
@@ -9,13 +9,67 @@ RETURNING *;
 delete from chirps where 1 = 1;
 
 -- name: GetAllChirps :many
-select * from chirps order by created_at;
+-- A null page_limit returns every chirp from page_offset on. id breaks ties
+-- so that pages don't overlap.
+select chirps.* from chirps
+join users on users.id = chirps.user_id
+where users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = sqlc.narg('viewer_id')::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = sqlc.narg('viewer_id')::uuid)
+order by
+  case when sqlc.arg(newest)::boolean then chirps.created_at end desc,
+  chirps.created_at, chirps.id
+limit sqlc.narg('page_limit')::int offset sqlc.arg(page_offset)::int;
 
 -- name: GetAllChirpsFromAuthorID :many
-select * from chirps where user_id = $1 order by created_at;
+select chirps.* from chirps
+join users on users.id = chirps.user_id
+where chirps.user_id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = sqlc.narg('viewer_id')::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = sqlc.narg('viewer_id')::uuid)
+order by
+  case when sqlc.arg(newest)::boolean then chirps.created_at end desc,
+  chirps.created_at, chirps.id
+limit sqlc.narg('page_limit')::int offset sqlc.arg(page_offset)::int;
 
 -- name: GetChirpByID :one
-select * from chirps where id = $1;
+select chirps.* from chirps
+join users on users.id = chirps.user_id
+where chirps.id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = sqlc.narg('viewer_id')::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = sqlc.narg('viewer_id')::uuid);
 
 -- name: DeleteChirpByID :exec
-delete from chirps where id = $1;
\ No newline at end of file
+delete from chirps where id = $1;
+
+-- name: DeleteChirpByIDReturning :one
+-- Deletes a chirp whoever can see it, for operators.
+delete from chirps where id = $1
+RETURNING *;
+
+-- name: GetChirpsForExport :many
+select * from chirps where user_id = $1 order by created_at;
+
+-- name: GetRecentChirpBodiesByUser :many
+-- excluded_id leaves out the chirp being edited, so that it isn't counted
+-- as a duplicate of itself.
+select body from chirps
+where user_id = $1 and created_at > sqlc.arg(since)::timestamp
+  and (sqlc.narg('excluded_id')::uuid is null or id <> sqlc.narg('excluded_id')::uuid)
+order by created_at desc
+limit 100;
+
+-- name: UpdateChirpBody :one
+update chirps
+set body = $2, updated_at = NOW()
+where id = $1
+RETURNING *;
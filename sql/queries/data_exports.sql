@@ -0,0 +1,24 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, 'pending'
+)
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at;
+
+-- name: GetDataExport :one
+select id, created_at, updated_at, user_id, status, error, completed_at
+from data_exports
+where id = $1;
+
+-- name: GetDataExportArchive :one
+select archive from data_exports where id = $1 and status = 'ready';
+
+-- name: CompleteDataExport :exec
+update data_exports
+set status = 'ready', archive = $2, completed_at = NOW(), updated_at = NOW()
+where id = $1;
+
+-- name: FailDataExport :exec
+update data_exports
+set status = 'failed', error = $2, completed_at = NOW(), updated_at = NOW()
+where id = $1;
@@ -0,0 +1,45 @@
+-- name: EnqueueJob :one
+-- Returns no rows when a pending or running job already has the unique key.
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
+)
+ON CONFLICT (unique_key) WHERE status in ('pending', 'running') DO NOTHING
+RETURNING *;
+
+-- name: ClaimJob :one
+update jobs
+set status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+where id = (
+    select id from jobs
+    where status = 'pending' and run_at <= NOW() and kind = any(sqlc.arg(kinds)::text[])
+    order by run_at
+    limit 1
+    for update skip locked
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+update jobs
+set status = 'succeeded', locked_at = null, finished_at = NOW(), last_error = null, updated_at = NOW()
+where id = $1;
+
+-- name: RetryJob :exec
+update jobs
+set status = 'pending', locked_at = null, run_at = $2, last_error = $3, updated_at = NOW()
+where id = $1;
+
+-- name: FailJob :exec
+update jobs
+set status = 'failed', locked_at = null, finished_at = NOW(), last_error = $2, updated_at = NOW()
+where id = $1;
+
+-- name: ReleaseStaleJobs :execrows
+-- Puts jobs whose worker died mid-run back in the queue.
+update jobs
+set status = 'pending', locked_at = null, updated_at = NOW()
+where status = 'running' and locked_at < sqlc.arg(stale_before)::timestamp;
+
+-- name: DeleteFinishedJobs :execrows
+delete from jobs
+where status in ('succeeded', 'failed') and finished_at < sqlc.arg(finished_before)::timestamp;
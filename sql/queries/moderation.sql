@@ -0,0 +1,28 @@
+-- name: ListModerationWords :many
+select * from moderation_words order by term;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (id, created_at, updated_at, term, action)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2
+)
+ON CONFLICT (term) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+delete from moderation_words where id = $1;
+
+-- name: CreateModerationFlag :one
+INSERT INTO moderation_flags (id, created_at, chirp_id, source, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3
+)
+RETURNING *;
+
+-- name: ListOpenModerationFlags :many
+select moderation_flags.*, chirps.body, chirps.user_id
+from moderation_flags
+join chirps on chirps.id = moderation_flags.chirp_id
+where moderation_flags.resolved_at is null
+order by moderation_flags.created_at;
@@ -0,0 +1,78 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: ListWebhookEndpointsForUser :many
+select * from webhook_endpoints where user_id = $1 order by created_at;
+
+-- name: GetWebhookEndpoint :one
+select * from webhook_endpoints where id = $1;
+
+-- name: DeleteWebhookEndpoint :execrows
+delete from webhook_endpoints where id = $1 and user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues a delivery of the event to every endpoint of the user subscribed
+-- to it.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at, trace_context)
+select gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, sqlc.arg(event_type)::text, sqlc.arg(payload)::text, NOW(), sqlc.arg(trace_context)::jsonb
+from webhook_endpoints
+where webhook_endpoints.user_id = sqlc.arg(user_id) and sqlc.arg(event_type)::text = any(webhook_endpoints.events);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at, trace_context)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, NOW(), $4
+)
+RETURNING *;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Leases due deliveries by pushing their next attempt into the future, so
+-- that a delivery whose worker crashes is picked up again once the lease
+-- runs out.
+update webhook_deliveries
+set next_attempt_at = sqlc.arg(lease_until)::timestamp, updated_at = NOW()
+where id in (
+    select id from webhook_deliveries
+    where status = 'pending' and next_attempt_at <= NOW()
+    order by next_attempt_at
+    limit sqlc.arg(batch_size)
+    for update skip locked
+)
+RETURNING *;
+
+-- name: FinishWebhookDeliveryAttempt :exec
+update webhook_deliveries
+set status = $2, attempts = attempts + 1, next_attempt_at = $3,
+    last_status_code = $4, last_error = $5, updated_at = NOW()
+where id = $1;
+
+-- name: RedeliverWebhookDelivery :one
+-- Attempts start over, so that the delivery gets its retries again.
+update webhook_deliveries
+set status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+where id = $1 and status = 'dead'
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, duration_ms, status_code, error)
+VALUES (
+    gen_random_uuid(), $1, $2, $3, $4, $5
+);
+
+-- name: ListWebhookDeliveriesForEndpoint :many
+select * from webhook_deliveries
+where endpoint_id = $1
+order by created_at desc
+limit 100;
+
+-- name: GetWebhookDelivery :one
+select * from webhook_deliveries where id = $1;
+
+-- name: ListWebhookDeliveryAttempts :many
+select * from webhook_delivery_attempts
+where delivery_id = $1
+order by attempted_at;
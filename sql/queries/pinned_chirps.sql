@@ -0,0 +1,23 @@
+-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+delete from pinned_chirps where user_id = $1 and chirp_id = $2;
+
+-- name: CountPinnedChirps :one
+select count(*) from pinned_chirps where user_id = $1;
+
+-- name: GetPinnedChirpsForUser :many
+select chirps.* from pinned_chirps
+join chirps on chirps.id = pinned_chirps.chirp_id
+join users on users.id = chirps.user_id
+where pinned_chirps.user_id = $1 and users.deleted_at is null
+  and not exists (
+    select 1 from hidden_chirps where hidden_chirps.chirp_id = chirps.id
+      and not (hidden_chirps.silent and chirps.user_id = sqlc.narg('viewer_id')::uuid)
+  )
+  and (users.shadow_banned_at is null or chirps.user_id = sqlc.narg('viewer_id')::uuid)
+order by pinned_chirps.pinned_at desc
+limit sqlc.arg(max_pinned)::int;
@@ -0,0 +1,15 @@
+-- name: EnsureRateLimitBucket :exec
+INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetRateLimitBucketForUpdate :one
+select * from rate_limit_buckets where key = $1 FOR UPDATE;
+
+-- name: UpdateRateLimitBucket :exec
+update rate_limit_buckets
+set tokens = $2, updated_at = $3
+where key = $1;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+delete from rate_limit_buckets where updated_at < $1;
@@ -17,4 +17,21 @@ RETURNING *;
 
 -- name: DeleteAllRefreshTokens :exec
 DELETE FROM refresh_tokens WHERE 1 = 1;
---
\ No newline at end of file
+--
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+--
+-- name: GetActiveRefreshTokensForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY created_at;
+--
+-- name: DeleteStaleRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1 OR revoked_at < $1;
+--
+-- name: CountActiveRefreshTokens :one
+SELECT count(*) FROM refresh_tokens
+WHERE revoked_at IS NULL AND expires_at > NOW();
@@ -0,0 +1,45 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, chirp_body, chirp_user_id, reporter_id, reason, details, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6, 'open'
+)
+RETURNING *;
+
+-- name: GetReport :one
+select * from reports where id = $1;
+
+-- name: ListReportsByStatus :many
+select * from reports where status = $1 order by created_at;
+
+-- name: ClaimReport :one
+update reports
+set status = 'claimed', claimed_by = $2, claimed_at = NOW(), updated_at = NOW()
+where id = $1 and status = 'open'
+RETURNING *;
+
+-- name: ResolveReport :one
+-- A claimed report can only be resolved by whoever claimed it.
+update reports
+set status = 'resolved', resolved_by = $2, resolved_at = NOW(), resolution = $3, updated_at = NOW()
+where id = $1 and (status = 'open' or (status = 'claimed' and claimed_by = $2))
+RETURNING *;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, actor_id, report_id, action, target_user_id, target_chirp_id, reason)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: ListModerationActionsForReport :many
+select * from moderation_actions where report_id = $1 order by created_at;
+
+-- name: ListWarningsForUser :many
+select id, created_at, reason from moderation_actions
+where target_user_id = $1 and action = 'warn_user'
+order by created_at desc;
+
+-- name: HideChirp :exec
+INSERT INTO hidden_chirps (chirp_id, hidden_at, hidden_by, reason)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (chirp_id) DO NOTHING;
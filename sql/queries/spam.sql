@@ -0,0 +1,16 @@
+-- name: CreateSpamDetection :one
+INSERT INTO spam_detections (id, created_at, user_id, chirp_id, body, reasons, action)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListSpamDetections :many
+select * from spam_detections
+where created_at > sqlc.arg(since)::timestamp
+order by created_at desc;
+
+-- name: HideChirpSilently :exec
+INSERT INTO hidden_chirps (chirp_id, hidden_at, reason, silent)
+VALUES ($1, NOW(), $2, true)
+ON CONFLICT (chirp_id) DO NOTHING;
@@ -0,0 +1,31 @@
+-- name: CreateSubscriptionChange :one
+INSERT INTO subscriptions (id, created_at, user_id, webhook_event_id, event, from_status, to_status, previous_expires_at, expires_at)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetLatestSubscriptionChange :one
+select * from subscriptions
+where user_id = $1
+order by created_at desc
+limit 1;
+
+-- name: ListSubscriptionChangesForUser :many
+select * from subscriptions
+where user_id = $1
+order by created_at;
+
+-- name: ListLapsedChirpyRedUsers :many
+-- Users whose Chirpy Red ran out without a cancellation or refund ending
+-- it, and who haven't been marked expired yet.
+select users.id, users.chirpy_red_expires_at from users
+where users.chirpy_red_expires_at <= NOW() and users.deleted_at is null
+  and coalesce((
+    select to_status from subscriptions
+    where subscriptions.user_id = users.id
+    order by created_at desc
+    limit 1
+  ), 'active') in ('active', 'past_due')
+order by users.chirpy_red_expires_at
+limit 500;
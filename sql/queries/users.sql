@@ -22,3 +22,55 @@ update users
 set updated_at=NOW(), chirpy_red_expires_at=$2
 where id = $1
 RETURNING id, created_at, updated_at, email, chirpy_red_expires_at;
+
+-- name: GetUserByID :one
+select * from users where id = $1;
+
+-- name: IsActiveUser :one
+-- Reports whether the user exists and isn't scheduled for deletion.
+select exists(select 1 from users where id = $1 and deleted_at is null);
+
+-- name: ScheduleUserDeletion :one
+update users
+set deleted_at = NOW(), updated_at = NOW()
+where id = $1 and deleted_at is null
+RETURNING id, created_at, updated_at, email, chirpy_red_expires_at, deleted_at;
+
+-- name: RestoreUser :one
+update users
+set deleted_at = null, updated_at = NOW()
+where id = $1 and deleted_at is not null and deleted_at > sqlc.arg(deleted_after)::timestamp
+RETURNING id, created_at, updated_at, email, chirpy_red_expires_at, deleted_at;
+
+-- name: PurgeDeletedUsers :execrows
+delete from users
+where deleted_at is not null and deleted_at <= sqlc.arg(deleted_before)::timestamp;
+
+-- name: SuspendUser :exec
+update users
+set suspended_until = $2, updated_at = NOW()
+where id = $1;
+
+-- name: ListUsers :many
+select id, created_at, updated_at, email, role, chirpy_red_expires_at, suspended_until, banned_at, shadow_banned_at, deleted_at
+from users
+order by created_at;
+
+-- name: UpdateUserRole :one
+update users
+set role = $2, updated_at = NOW()
+where id = $1
+RETURNING id, created_at, updated_at, email, role;
+
+-- name: SetUserBan :exec
+update users
+set banned_at = $2, updated_at = NOW()
+where id = $1;
+
+-- name: SetUserShadowBan :exec
+update users
+set shadow_banned_at = $2, updated_at = NOW()
+where id = $1;
+
+-- name: GetUserByIDForUpdate :one
+select * from users where id = $1 for update;
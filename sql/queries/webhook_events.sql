@@ -0,0 +1,36 @@
+-- name: CreateWebhookEvent :one
+-- Returns no rows when the provider already sent an event with this ID.
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES (
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, 'processing'
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+select * from webhook_events where id = $1;
+
+-- name: GetWebhookEventByEventID :one
+select * from webhook_events where provider = $1 and event_id = $2;
+
+-- name: RetryWebhookEvent :one
+-- Claims a failed event for another attempt, or one stuck in processing
+-- since before stale_before because the attempt died. Returns no rows when
+-- the event has already been handled or another attempt is in progress.
+update webhook_events
+set status = 'processing', attempts = attempts + 1, error = null, updated_at = NOW()
+where id = $1
+  and (status = 'failed' or (status = 'processing' and updated_at < sqlc.arg(stale_before)::timestamp))
+RETURNING *;
+
+-- name: FinishWebhookEvent :exec
+update webhook_events
+set status = $2, error = $3, updated_at = NOW(),
+    processed_at = case when $2 = 'failed' then processed_at else NOW() end
+where id = $1;
+
+-- name: ListWebhookEvents :many
+select * from webhook_events
+where sqlc.narg('status')::text is null or status = sqlc.narg('status')::text
+order by created_at desc
+limit 200;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+add column deleted_at timestamp DEFAULT null;
+
+ALTER TABLE chirps
+drop CONSTRAINT fk_user_id,
+add CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE refresh_tokens
+drop CONSTRAINT fk_user_id,
+add CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+drop CONSTRAINT fk_user_id,
+add CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE chirps
+drop CONSTRAINT fk_user_id,
+add CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE users
+drop COLUMN deleted_at;
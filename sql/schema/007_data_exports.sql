@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE data_exports (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  user_id UUID not null,
+  status text not null DEFAULT 'pending',
+  archive bytea,
+  error text,
+  completed_at TIMESTAMP,
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT status_check CHECK (status in ('pending', 'ready', 'failed'))
+);
+
+-- +goose Down
+DROP TABLE data_exports;
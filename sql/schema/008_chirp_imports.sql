@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE chirp_imports (
+  user_id UUID not null,
+  source_id text not null,
+  chirp_id UUID not null,
+  imported_at TIMESTAMP not null,
+  PRIMARY KEY (user_id, source_id),
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_imports;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  term text UNIQUE not null,
+  action text not null,
+  CONSTRAINT action_check CHECK (action in ('mask', 'reject', 'flag'))
+);
+
+INSERT INTO moderation_words (created_at, updated_at, term, action)
+VALUES
+  (NOW(), NOW(), 'kerfuffle', 'mask'),
+  (NOW(), NOW(), 'sharbert', 'mask'),
+  (NOW(), NOW(), 'fornax', 'mask');
+
+CREATE TABLE moderation_flags (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  chirp_id UUID not null,
+  source text not null,
+  reason text not null,
+  resolved_at TIMESTAMP,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE moderation_flags;
+DROP TABLE moderation_words;
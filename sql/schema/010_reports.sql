@@ -0,0 +1,62 @@
+-- +goose Up
+ALTER TABLE users
+add column suspended_until timestamp DEFAULT null;
+
+CREATE TABLE hidden_chirps (
+  chirp_id UUID PRIMARY KEY,
+  hidden_at TIMESTAMP not null,
+  hidden_by UUID,
+  reason text not null,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+  CONSTRAINT fk_hidden_by FOREIGN KEY (hidden_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE reports (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  chirp_id UUID,
+  chirp_body text not null,
+  chirp_user_id UUID not null,
+  reporter_id UUID not null,
+  reason text not null,
+  details text not null DEFAULT '',
+  status text not null DEFAULT 'open',
+  claimed_by UUID,
+  claimed_at TIMESTAMP,
+  resolved_by UUID,
+  resolved_at TIMESTAMP,
+  resolution text,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL,
+  CONSTRAINT fk_reporter_id FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_claimed_by FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL,
+  CONSTRAINT fk_resolved_by FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL,
+  CONSTRAINT reason_check CHECK (reason in ('spam', 'harassment', 'hate_speech', 'misinformation', 'other')),
+  CONSTRAINT status_check CHECK (status in ('open', 'claimed', 'resolved'))
+);
+
+CREATE UNIQUE INDEX reports_unresolved_per_reporter_idx
+ON reports (chirp_id, reporter_id)
+WHERE resolved_at IS NULL;
+
+CREATE TABLE moderation_actions (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  actor_id UUID,
+  report_id UUID,
+  action text not null,
+  target_user_id UUID,
+  target_chirp_id UUID,
+  reason text not null,
+  CONSTRAINT fk_actor_id FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+  CONSTRAINT fk_report_id FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL,
+  CONSTRAINT action_check CHECK (action in ('hide_chirp', 'delete_chirp', 'warn_user', 'suspend_user', 'dismiss'))
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE hidden_chirps;
+
+ALTER TABLE users
+drop COLUMN suspended_until;
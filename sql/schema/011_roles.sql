@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+add column role text DEFAULT 'user' not null,
+add CONSTRAINT role_check CHECK (role in ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+drop CONSTRAINT role_check,
+drop COLUMN role;
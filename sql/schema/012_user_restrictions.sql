@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+add column banned_at timestamp DEFAULT null,
+add column shadow_banned_at timestamp DEFAULT null;
+
+ALTER TABLE moderation_actions
+drop CONSTRAINT action_check,
+add CONSTRAINT action_check CHECK (action in (
+  'hide_chirp', 'delete_chirp', 'warn_user', 'suspend_user', 'dismiss',
+  'unsuspend_user', 'ban_user', 'unban_user', 'shadow_ban_user', 'unshadow_ban_user'
+));
+
+-- +goose Down
+ALTER TABLE moderation_actions
+drop CONSTRAINT action_check,
+add CONSTRAINT action_check CHECK (action in ('hide_chirp', 'delete_chirp', 'warn_user', 'suspend_user', 'dismiss'));
+
+ALTER TABLE users
+drop COLUMN shadow_banned_at,
+drop COLUMN banned_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE UNLOGGED TABLE rate_limit_buckets (
+  key text PRIMARY KEY,
+  tokens double precision not null,
+  updated_at TIMESTAMP not null
+);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
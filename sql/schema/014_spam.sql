@@ -0,0 +1,24 @@
+-- +goose Up
+-- Silently hidden chirps stay visible to their author.
+ALTER TABLE hidden_chirps
+add column silent boolean not null DEFAULT false;
+
+CREATE TABLE spam_detections (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  user_id UUID not null,
+  chirp_id UUID,
+  body text not null,
+  reasons text[] not null,
+  action text not null,
+  CONSTRAINT action_check CHECK (action in ('reject', 'review', 'throttle')),
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX spam_detections_created_at_idx ON spam_detections (created_at);
+
+-- +goose Down
+DROP TABLE spam_detections;
+ALTER TABLE hidden_chirps
+drop column silent;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  provider text not null,
+  event_id text,
+  event_type text not null,
+  payload text not null,
+  status text not null,
+  error text,
+  attempts integer not null DEFAULT 1,
+  processed_at TIMESTAMP,
+  CONSTRAINT status_check CHECK (status in ('processing', 'processed', 'ignored', 'failed'))
+);
+
+CREATE UNIQUE INDEX webhook_events_provider_event_id_idx ON webhook_events (provider, event_id);
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  user_id UUID not null,
+  webhook_event_id UUID,
+  event text not null,
+  from_status text not null,
+  to_status text not null,
+  previous_expires_at TIMESTAMP,
+  expires_at TIMESTAMP,
+  CONSTRAINT status_check CHECK (to_status in ('active', 'past_due', 'canceled', 'refunded')),
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_webhook_event_id FOREIGN KEY (webhook_event_id) REFERENCES webhook_events(id) ON DELETE SET NULL
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions (user_id, created_at);
+
+-- +goose Down
+DROP TABLE subscriptions;
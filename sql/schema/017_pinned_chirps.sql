@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE pinned_chirps (
+  user_id UUID not null,
+  chirp_id UUID not null,
+  pinned_at TIMESTAMP not null,
+  PRIMARY KEY (user_id, chirp_id),
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_chirp_id FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
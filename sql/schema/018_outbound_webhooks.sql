@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  user_id UUID not null,
+  url text not null,
+  secret text not null,
+  events text[] not null,
+  CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_endpoints_user_id_idx ON webhook_endpoints (user_id);
+
+CREATE TABLE webhook_deliveries (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  endpoint_id UUID not null,
+  event_type text not null,
+  payload text not null,
+  status text not null DEFAULT 'pending',
+  attempts integer not null DEFAULT 0,
+  next_attempt_at TIMESTAMP not null,
+  last_status_code integer,
+  last_error text,
+  CONSTRAINT status_check CHECK (status in ('pending', 'succeeded', 'dead')),
+  CONSTRAINT fk_endpoint_id FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at);
+
+CREATE TABLE webhook_delivery_attempts (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  delivery_id UUID not null,
+  attempted_at TIMESTAMP not null,
+  duration_ms integer not null,
+  status_code integer,
+  error text,
+  CONSTRAINT fk_delivery_id FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
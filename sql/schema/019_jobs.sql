@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE jobs (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP not null,
+  updated_at TIMESTAMP not null,
+  kind text not null,
+  payload jsonb not null,
+  status text not null DEFAULT 'pending',
+  attempts integer not null DEFAULT 0,
+  max_attempts integer not null,
+  run_at TIMESTAMP not null,
+  locked_at TIMESTAMP,
+  finished_at TIMESTAMP,
+  last_error text,
+  -- At most one pending or running job exists per unique key. Recurring
+  -- jobs use it so that every instance scheduling them doesn't pile up
+  -- copies.
+  unique_key text,
+  CONSTRAINT status_check CHECK (status in ('pending', 'running', 'succeeded', 'failed'))
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key) WHERE status in ('pending', 'running');
+
+ALTER TABLE subscriptions
+drop constraint status_check,
+add CONSTRAINT status_check CHECK (to_status in ('active', 'past_due', 'canceled', 'refunded', 'expired'));
+
+-- +goose Down
+DELETE FROM subscriptions WHERE to_status = 'expired';
+ALTER TABLE subscriptions
+drop constraint status_check,
+add CONSTRAINT status_check CHECK (to_status in ('active', 'past_due', 'canceled', 'refunded'));
+
+DROP TABLE jobs;
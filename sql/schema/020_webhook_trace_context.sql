@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE webhook_deliveries ADD COLUMN trace_context jsonb not null DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE webhook_deliveries DROP COLUMN trace_context;
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+// subscriptionState reads a user's Chirpy Red subscription. Users upgraded
+// before the subscriptions history existed have no history rows, so their
+// status is derived from chirpy_red_expires_at alone.
+func subscriptionState(ctx context.Context, q *database.Queries, user database.User) (billing.State, error) {
+	state := billing.State{Status: billing.StatusNone}
+	if user.ChirpyRedExpiresAt.Valid {
+		state.ExpiresAt = user.ChirpyRedExpiresAt.Time
+	}
+	latest, err := q.GetLatestSubscriptionChange(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if time.Now().Before(state.ExpiresAt) {
+			state.Status = billing.StatusActive
+		}
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	state.Status = billing.Status(latest.ToStatus)
+	return state, nil
+}
+
+// applySubscriptionEvent moves userID's subscription through event and
+// records the transition, along with the Polka webhook that caused it if
+// any. q must be bound to a transaction, as the user row is locked until
+// the transition is written.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, q *database.Queries, webhookEventID uuid.NullUUID, userID uuid.UUID, event billing.Event) error {
+	user, err := q.GetUserByIDForUpdate(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	current, err := subscriptionState(ctx, q, user)
+	if err != nil {
+		return err
+	}
+	next, err := cfg.billing.Apply(current, event, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = q.UpdateUserChirpyRed(ctx, database.UpdateUserChirpyRedParams{
+		ID:                 userID,
+		ChirpyRedExpiresAt: sql.NullTime{Time: next.ExpiresAt, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.CreateSubscriptionChange(ctx, database.CreateSubscriptionChangeParams{
+		UserID:            userID,
+		WebhookEventID:    webhookEventID,
+		Event:             string(event),
+		FromStatus:        string(current.Status),
+		ToStatus:          string(next.Status),
+		PreviousExpiresAt: sql.NullTime{Time: current.ExpiresAt, Valid: !current.ExpiresAt.IsZero()},
+		ExpiresAt:         sql.NullTime{Time: next.ExpiresAt, Valid: true},
+	})
+	return err
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/wilgnert/chirpy/internal/auth"
 	"github.com/wilgnert/chirpy/internal/database"
 )
@@ -22,7 +20,7 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	if err := decoder.Decode(&p); err != nil {
 		if err := respondWithError(w, http.StatusInternalServerError, "Something went wrong"); err != nil {
-			fmt.Println("Could not respond to request")
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 		}
 		return
 	}
@@ -37,10 +35,24 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 		"created_at": user.CreatedAt.String(),
 		"updated_at": user.UpdatedAt.String(),
 		"email": user.Email,
-		"is_chirpy_red": user.ChirpyRedExpiresAt.Valid && time.Now().Before(user.ChirpyRedExpiresAt.Time),
+		"is_chirpy_red": isChirpyRed(user.ChirpyRedExpiresAt),
 	})
 }
 
+// signInRestriction explains why user may not get new tokens, or returns an
+// empty string if they may. Shadow-banned users are deliberately let in.
+func signInRestriction(user database.User) string {
+	switch {
+	case user.DeletedAt.Valid:
+		return "account is scheduled for deletion"
+	case user.BannedAt.Valid:
+		return "account is banned"
+	case user.SuspendedUntil.Valid && time.Now().Before(user.SuspendedUntil.Time):
+		return fmt.Sprintf("account is suspended until %s", user.SuspendedUntil.Time.String())
+	}
+	return ""
+}
+
 func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email string `json:"email"`
@@ -51,20 +63,17 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	if err := decoder.Decode(&p); err != nil {
 		if err := respondWithError(w, http.StatusInternalServerError, "Something went wrong"); err != nil {
-			fmt.Println("Could not respond to request")
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 		}
 		return
 	}
 
-	ExpiresInSeconds := 60 * 60
-	
-	
 	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), p.Email)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Duration(ExpiresInSeconds) * time.Second)
+	token, err := auth.MakeJWTWithRole(user.ID, auth.Role(user.Role), cfg.secret, cfg.tokens.AccessTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return
@@ -73,12 +82,16 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
+	if msg := signInRestriction(user); msg != "" {
+		respondWithError(w, http.StatusForbidden, msg)
+		return
+	}
 	refresh_token, err := auth.MakeRefreshToken()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
-  rfsh_tkn, err := cfg.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{UserID: user.ID, Token: refresh_token, ExpiresAt: time.Now().Add(60 * 24 * time.Hour)})
+  rfsh_tkn, err := cfg.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{UserID: user.ID, Token: refresh_token, ExpiresAt: time.Now().Add(cfg.tokens.RefreshTTL)})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return
@@ -91,7 +104,7 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		"email": user.Email,
 		"token": token,
 		"refresh_token": rfsh_tkn.Token,
-		"is_chirpy_red": user.ChirpyRedExpiresAt.Valid && time.Now().Before(user.ChirpyRedExpiresAt.Time),
+		"is_chirpy_red": isChirpyRed(user.ChirpyRedExpiresAt),
 	})
 }
 
@@ -123,7 +136,16 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "token expired")
 		return
 	}
-	new_token , err := auth.MakeJWT(refresh_token.UserID, cfg.secret, time.Duration(60 * 60) * time.Second)
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), refresh_token.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if msg := signInRestriction(user); msg != "" {
+		respondWithError(w, http.StatusForbidden, msg)
+		return
+	}
+	new_token , err := auth.MakeJWTWithRole(user.ID, auth.Role(user.Role), cfg.secret, cfg.tokens.AccessTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Internal server error")
 		return
@@ -173,7 +195,7 @@ func (cfg *apiConfig) updateUserEmailAndPassword(w http.ResponseWriter, r *http.
 		respondWithError(w, http.StatusUnauthorized, "missing token in Authorization header")
 		return
 	}
-	id, err := auth.ValidateJWT(bearerToken, cfg.secret)
+	id, err := cfg.validateAccessToken(r.Context(), bearerToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "invalid token")
 		return
@@ -188,7 +210,7 @@ func (cfg *apiConfig) updateUserEmailAndPassword(w http.ResponseWriter, r *http.
 	defer r.Body.Close()
 	if err := decoder.Decode(&p); err != nil {
 		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
-			fmt.Println("Could not respond to request")
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 		}
 		return
 	}
@@ -196,13 +218,13 @@ func (cfg *apiConfig) updateUserEmailAndPassword(w http.ResponseWriter, r *http.
 	hashed, err := auth.HashPassword(p.Password)
 	if  err != nil {
 		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
-			fmt.Println("Could not respond to request")
+			loggerFrom(r.Context()).Error("could not respond to request", "error", err)
 		}
 		return
 	}
 	u, err = cfg.dbQueries.UpdateUserEmailAndPassword(r.Context(), database.UpdateUserEmailAndPasswordParams{ID: id, Email: p.Email, HashedPassword: hashed})
 	if err != nil {
-		fmt.Println(err.Error())
+		loggerFrom(r.Context()).Error("could not find user to update credentials with the request", "error", err)
 		respondWithError(w, http.StatusNotFound, "could not find user to update credentials with the request")
 		return
 	}
@@ -213,53 +235,6 @@ func (cfg *apiConfig) updateUserEmailAndPassword(w http.ResponseWriter, r *http.
 		"updated_at": u.UpdatedAt.String(),
 		"email": u.Email,
 		"token": bearerToken,
-		"is_chirpy_red": u.ChirpyRedExpiresAt.Valid && time.Now().Before(u.ChirpyRedExpiresAt.Time),
+		"is_chirpy_red": isChirpyRed(u.ChirpyRedExpiresAt),
 	})
 }
-
-func (cfg *apiConfig) handleWebhook(w http.ResponseWriter, r *http.Request) {
-	key, err := auth.GetAPIKey(r.Header)
-	if err != nil || key != cfg.polka_key {
-		respondWithError(w, http.StatusUnauthorized, "faulty api key")
-		return
-	}
-	var p struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	decoder := json.NewDecoder(r.Body)
-	defer r.Body.Close()
-	if err := decoder.Decode(&p); err != nil {
-		if err := respondWithError(w, http.StatusBadRequest, "could not parse request body"); err != nil {
-			fmt.Println("Could not respond to request")
-		}
-		return
-	}
-	switch p.Event {
-	case "user.upgraded":
-		parsed_id, err := uuid.Parse(p.Data.UserID)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "could not parse user")
-			return
-		}
-		_, err = cfg.dbQueries.UpdateUserChirpyRed(
-			r.Context(), 
-			database.UpdateUserChirpyRedParams{
-				ID: parsed_id, 
-				ChirpyRedExpiresAt: sql.NullTime{
-					Time: time.Now().Add(30*24*time.Hour),
-					Valid: true,
-				},
-			},
-		)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "could not find user")
-			return
-		}
-	default:
-		break
-	}
-	RespondNoContent(w, r)
-}
\ No newline at end of file
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wilgnert/chirpy/internal/auth"
+	"github.com/wilgnert/chirpy/internal/billing"
+	"github.com/wilgnert/chirpy/internal/database"
+)
+
+const maxWebhookSize = 1 << 20
+
+// webhookEventLease is how long an event may stay in processing before a
+// redelivery or replay assumes the attempt died and takes it over.
+const webhookEventLease = 5 * time.Minute
+
+// authenticateWebhook accepts a webhook signed with one of the configured
+// Polka secrets. The static ApiKey header is only accepted when
+// POLKA_ALLOW_API_KEY is set, for senders that don't sign yet.
+func (cfg *apiConfig) authenticateWebhook(r *http.Request, body []byte) error {
+	err := auth.VerifyWebhookSignature(r.Header, body, cfg.polkaWebhookSecrets, cfg.polkaWebhookTolerance, time.Now())
+	if err == nil || !cfg.polkaAllowAPIKey || r.Header.Get(auth.WebhookSignatureHeader) != "" {
+		return err
+	}
+	key, keyErr := auth.GetAPIKey(r.Header)
+	if keyErr != nil {
+		return keyErr
+	}
+	if cfg.polka_key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.polka_key)) != 1 {
+		return errors.New("faulty api key")
+	}
+	return nil
+}
+
+var (
+	errInvalidWebhookPayload = errors.New("could not parse webhook payload")
+	errWebhookUserNotFound   = errors.New("could not find user")
+)
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+	} `json:"data"`
+}
+
+// handleWebhook records every authenticated webhook in webhook_events before
+// acting on it. Events are deduplicated by webhookEventKey: a redelivery of
+// an event that was already handled is acknowledged without being applied
+// again, and one that failed before is retried.
+func (cfg *apiConfig) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not read request body")
+		return
+	}
+	if err := cfg.authenticateWebhook(r, body); err != nil {
+		cfg.metrics.WebhookOutcomes.WithLabelValues("inbound", "unauthorized").Inc()
+		respondWithError(w, http.StatusUnauthorized, "invalid webhook signature")
+		return
+	}
+	// Unparseable payloads are still recorded; processing marks them failed.
+	var p polkaEvent
+	json.Unmarshal(body, &p)
+	eventID := sql.NullString{String: webhookEventKey(p, body), Valid: true}
+
+	event, err := cfg.dbQueries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:  "polka",
+		EventID:   eventID,
+		EventType: p.Event,
+		Payload:   string(body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := cfg.dbQueries.GetWebhookEventByEventID(r.Context(), database.GetWebhookEventByEventIDParams{
+			Provider: "polka",
+			EventID:  eventID,
+		})
+		if err == nil {
+			event, err = cfg.dbQueries.RetryWebhookEvent(r.Context(), database.RetryWebhookEventParams{
+				ID:          existing.ID,
+				StaleBefore: time.Now().Add(-webhookEventLease),
+			})
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.metrics.WebhookOutcomes.WithLabelValues("inbound", "duplicate").Inc()
+			RespondNoContent(w, r)
+			return
+		}
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not record webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not record webhook")
+		return
+	}
+
+	// Processing carries on if Polka hangs up, so that the event doesn't
+	// stay in processing until its lease runs out.
+	err = cfg.processWebhookEvent(context.WithoutCancel(r.Context()), event)
+	switch {
+	case err == nil:
+		RespondNoContent(w, r)
+	case errors.Is(err, errInvalidWebhookPayload):
+		respondWithError(w, http.StatusBadRequest, "could not parse request body")
+	case errors.Is(err, errWebhookUserNotFound):
+		respondWithError(w, http.StatusNotFound, "could not find user")
+	default:
+		loggerFrom(r.Context()).Error("could not process webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not process webhook")
+	}
+}
+
+// webhookEventKey is what an event is deduplicated by: Polka's event ID, or
+// for payloads without one, like those of senders predating event IDs, a
+// hash of the body, so that a redelivery of the same body is still only
+// applied once.
+func webhookEventKey(p polkaEvent, body []byte) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// processWebhookEvent applies an event claimed by handleWebhook or
+// replayWebhookEvent. Its effect and the processed status are committed
+// together; on failure the event is marked failed with the error.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	err := cfg.applyWebhookEvent(ctx, event)
+	if err != nil {
+		cfg.metrics.WebhookOutcomes.WithLabelValues("inbound", "failed").Inc()
+		if err := cfg.dbQueries.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+			ID:     event.ID,
+			Status: "failed",
+			Error:  sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			loggerFrom(ctx).Error("marking webhook event as failed failed", "error", err)
+		}
+		return err
+	}
+	cfg.metrics.WebhookOutcomes.WithLabelValues("inbound", "processed").Inc()
+	return nil
+}
+
+func (cfg *apiConfig) applyWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	var p polkaEvent
+	if err := json.Unmarshal([]byte(event.Payload), &p); err != nil {
+		return errInvalidWebhookPayload
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	status := "processed"
+	var eventErr sql.NullString
+	if subscriptionEvent := billing.Event(p.Event); subscriptionEvent.Valid() {
+		userID, err := uuid.Parse(p.Data.UserID)
+		if err != nil {
+			return errWebhookUserNotFound
+		}
+		err = cfg.applySubscriptionEvent(ctx, qtx, uuid.NullUUID{UUID: event.ID, Valid: true}, userID, subscriptionEvent)
+		if errors.Is(err, billing.ErrInvalidTransition) {
+			// Retrying won't make the event apply, so it is acknowledged
+			// and kept for investigation instead of being failed.
+			status = "ignored"
+			eventErr = sql.NullString{String: err.Error(), Valid: true}
+		} else if err != nil {
+			return err
+		}
+	} else {
+		status = "ignored"
+	}
+
+	err = qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{ID: event.ID, Status: status, Error: eventErr})
+	if err == nil {
+		err = tx.Commit()
+	}
+	return err
+}
+
+func webhookEventResponse(event database.WebhookEvent) map[string]any {
+	res := map[string]any{
+		"id":           event.ID.String(),
+		"created_at":   event.CreatedAt.String(),
+		"updated_at":   event.UpdatedAt.String(),
+		"provider":     event.Provider,
+		"event_id":     nil,
+		"event_type":   event.EventType,
+		"payload":      json.RawMessage(event.Payload),
+		"status":       event.Status,
+		"error":        nil,
+		"attempts":     event.Attempts,
+		"processed_at": nullableTime(event.ProcessedAt),
+	}
+	if event.EventID.Valid {
+		res["event_id"] = event.EventID.String
+	}
+	if event.Error.Valid {
+		res["error"] = event.Error.String
+	}
+	if !json.Valid([]byte(event.Payload)) {
+		res["payload"] = event.Payload
+	}
+	return res
+}
+
+func (cfg *apiConfig) listWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	events, err := cfg.dbQueries.ListWebhookEvents(r.Context(), sql.NullString{String: status, Valid: status != ""})
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve webhook events", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve webhook events")
+		return
+	}
+	res := make([]map[string]any, 0, len(events))
+	for _, event := range events {
+		res = append(res, webhookEventResponse(event))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) getWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook event")
+		return
+	}
+	event, err := cfg.dbQueries.GetWebhookEvent(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook event")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventResponse(event))
+}
+
+func (cfg *apiConfig) replayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook event")
+		return
+	}
+	if _, err := cfg.dbQueries.GetWebhookEvent(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "could not find webhook event")
+		return
+	}
+	event, err := cfg.dbQueries.RetryWebhookEvent(r.Context(), database.RetryWebhookEventParams{
+		ID:          id,
+		StaleBefore: time.Now().Add(-webhookEventLease),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "only failed or stuck webhook events can be replayed")
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not replay webhook event", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not replay webhook event")
+		return
+	}
+	if err := cfg.processWebhookEvent(context.WithoutCancel(r.Context()), event); err != nil {
+		loggerFrom(r.Context()).Warn("replayed webhook event failed again", "event_id", id, "error", err)
+	}
+	event, err = cfg.dbQueries.GetWebhookEvent(r.Context(), id)
+	if err != nil {
+		loggerFrom(r.Context()).Error("could not retrieve webhook event", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve webhook event")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventResponse(event))
+}